@@ -0,0 +1,391 @@
+package got
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Auth holds the credentials used to authenticate got requests.
+//
+// Credentials are only sent to the origin (scheme, host and port) of the download URL,
+// they are stripped when a request is redirected to another origin.
+type Auth struct {
+
+	// Username and Password used for Basic or Digest authentication,
+	// the scheme is picked from the server challenge.
+	Username, Password string
+
+	// Token is sent as a Bearer token.
+	Token string
+
+	// Netrc enables looking up the credentials in the netrc file when Username is empty.
+	Netrc bool
+
+	// NetrcFile is the netrc file path, defaults to $NETRC or ~/.netrc.
+	NetrcFile string
+}
+
+// authTransport adds the Authorization header to the requests sent to origin,
+// and answers Basic and Digest (RFC 7616) challenges.
+type authTransport struct {
+	base http.RoundTripper
+
+	origin string
+
+	token, username, password string
+
+	mu sync.Mutex
+
+	// scheme is the challenge scheme learned from the server, once it's known
+	// the credentials are sent with every request.
+	scheme string
+
+	digest *digestChallenge
+}
+
+type digestChallenge struct {
+	realm, nonce, opaque, algorithm, qop string
+
+	userhash bool
+
+	nc uint32
+}
+
+func newAuthTransport(base http.RoundTripper, auth *Auth, URL string) (*authTransport, error) {
+
+	u, err := url.Parse(URL)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &authTransport{
+		base:     base,
+		origin:   originOf(u),
+		token:    auth.Token,
+		username: auth.Username,
+		password: auth.Password,
+	}
+
+	if t.username == "" && t.token == "" && auth.Netrc {
+
+		machine, err := lookupNetrc(auth.NetrcFile, u.Hostname())
+		if err != nil {
+			return nil, err
+		}
+
+		if machine != nil {
+			t.username, t.password = machine.login, machine.password
+		}
+	}
+
+	return t, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	// Never send credentials to other origins, and keep user set headers as is.
+	if originOf(req.URL) != t.origin || req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	if t.token != "" {
+		r := req.Clone(req.Context())
+		r.Header.Set("Authorization", "Bearer "+t.token)
+		return t.base.RoundTrip(r)
+	}
+
+	if t.username == "" {
+		return t.base.RoundTrip(req)
+	}
+
+	r := req.Clone(req.Context())
+	t.authorize(r)
+
+	res, err := t.base.RoundTrip(r)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	// The request body can't be sent again.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return res, nil
+	}
+
+	if !t.challenge(res.Header.Values("WWW-Authenticate")) {
+		return res, nil
+	}
+
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	r = req.Clone(req.Context())
+	if req.GetBody != nil {
+		if r.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	t.authorize(r)
+
+	return t.base.RoundTrip(r)
+}
+
+// challenge picks the strongest supported scheme offered by the server,
+// it returns false when the request should not be sent again.
+func (t *authTransport) challenge(headers []string) bool {
+
+	var basic, digest *authChallenge
+
+	for _, c := range parseChallenges(headers) {
+
+		switch c.scheme {
+		case "digest":
+			// Prefer SHA-256 over MD5 when both are offered.
+			if _, ok := digestHash(c.params["algorithm"]); ok && (digest == nil || strings.HasPrefix(strings.ToUpper(c.params["algorithm"]), "SHA-256")) {
+				digest = c
+			}
+		case "basic":
+			basic = c
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case digest != nil:
+
+		// A digest challenge which is not stale means the credentials were rejected.
+		if t.scheme == "digest" && !strings.EqualFold(digest.params["stale"], "true") {
+			return false
+		}
+
+		t.scheme = "digest"
+		t.digest = &digestChallenge{
+			realm:     digest.params["realm"],
+			nonce:     digest.params["nonce"],
+			opaque:    digest.params["opaque"],
+			algorithm: digest.params["algorithm"],
+			userhash:  strings.EqualFold(digest.params["userhash"], "true"),
+		}
+
+		for _, qop := range strings.Split(digest.params["qop"], ",") {
+			if strings.TrimSpace(qop) == "auth" {
+				t.digest.qop = "auth"
+			}
+		}
+
+		return true
+
+	case basic != nil && t.scheme == "":
+		t.scheme = "basic"
+		return true
+	}
+
+	return false
+}
+
+func (t *authTransport) authorize(req *http.Request) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch t.scheme {
+	case "basic":
+		req.SetBasicAuth(t.username, t.password)
+	case "digest":
+		req.Header.Set("Authorization", t.digest.authorization(req, t.username, t.password))
+	}
+}
+
+// authorization returns the digest Authorization header value, it must be called with the lock held.
+func (c *digestChallenge) authorization(req *http.Request, username, password string) string {
+
+	var (
+		newHash, _ = digestHash(c.algorithm)
+		h          = func(s string) string {
+			hh := newHash()
+			io.WriteString(hh, s)
+			return hex.EncodeToString(hh.Sum(nil))
+		}
+		uri    = req.URL.RequestURI()
+		cnonce = newCnonce()
+	)
+
+	c.nc++
+	nc := fmt.Sprintf("%08x", c.nc)
+
+	ha1 := h(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToLower(c.algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+
+	ha2 := h(req.Method + ":" + uri)
+
+	var response string
+	if c.qop != "" {
+		response = h(ha1 + ":" + c.nonce + ":" + nc + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	}
+
+	if c.userhash {
+		username = h(username + ":" + c.realm)
+	}
+
+	params := []string{
+		fmt.Sprintf("username=%q", username),
+		fmt.Sprintf("realm=%q", c.realm),
+		fmt.Sprintf("nonce=%q", c.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		fmt.Sprintf("response=%q", response),
+	}
+
+	if c.algorithm != "" {
+		params = append(params, "algorithm="+c.algorithm)
+	}
+
+	if c.opaque != "" {
+		params = append(params, fmt.Sprintf("opaque=%q", c.opaque))
+	}
+
+	if c.qop != "" {
+		params = append(params, "qop="+c.qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+
+	if c.userhash {
+		params = append(params, "userhash=true")
+	}
+
+	return "Digest " + strings.Join(params, ", ")
+}
+
+func digestHash(algorithm string) (func() hash.Hash, bool) {
+
+	switch strings.ToUpper(algorithm) {
+	case "", "MD5", "MD5-SESS":
+		return md5.New, true
+	case "SHA-256", "SHA-256-SESS":
+		return sha256.New, true
+	}
+
+	return nil, false
+}
+
+func newCnonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type authChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenges parses WWW-Authenticate header values, a single value may hold many challenges.
+func parseChallenges(headers []string) (challenges []*authChallenge) {
+
+	for _, s := range headers {
+
+		var c *authChallenge
+
+		for s = strings.TrimLeft(s, " ,"); s != ""; s = strings.TrimLeft(s, " ,") {
+
+			var token string
+			token, s = readToken(s)
+
+			if token == "" {
+				// Skip invalid character.
+				s = s[1:]
+				continue
+			}
+
+			s = strings.TrimLeft(s, " ")
+
+			// A token followed by "=" is a param of the current challenge.
+			if strings.HasPrefix(s, "=") {
+
+				rest := strings.TrimLeft(s[1:], " ")
+
+				// token68 credentials are not used by supported schemes.
+				if c == nil || rest == "" || rest[0] == '=' || rest[0] == ',' {
+					s = strings.TrimLeft(s, "=")
+					continue
+				}
+
+				var value string
+				if strings.HasPrefix(rest, `"`) {
+					value, s = readQuoted(rest)
+				} else {
+					value, s = readToken(rest)
+				}
+
+				c.params[strings.ToLower(token)] = value
+				continue
+			}
+
+			c = &authChallenge{scheme: strings.ToLower(token), params: make(map[string]string)}
+			challenges = append(challenges, c)
+		}
+	}
+
+	return challenges
+}
+
+func readToken(s string) (string, string) {
+
+	i := 0
+	for ; i < len(s); i++ {
+		if strings.IndexByte(" \t,=\"", s[i]) != -1 {
+			break
+		}
+	}
+
+	return s[:i], s[i:]
+}
+
+func readQuoted(s string) (string, string) {
+
+	var b strings.Builder
+
+	for i := 1; i < len(s); i++ {
+
+		switch s[i] {
+		case '"':
+			return b.String(), s[i+1:]
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String(), ""
+}
+
+func originOf(u *url.URL) string {
+
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+
+	return strings.ToLower(u.Scheme + "://" + u.Hostname() + ":" + port)
+}
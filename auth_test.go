@@ -0,0 +1,236 @@
+package got_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/melbahja/got"
+)
+
+func TestAuth(t *testing.T) {
+
+	t.Run("basicAuthTest", basicAuthTest)
+	t.Run("digestAuthTest", digestAuthTest)
+	t.Run("bearerAuthTest", bearerAuthTest)
+	t.Run("netrcAuthTest", netrcAuthTest)
+	t.Run("crossOriginRedirectTest", crossOriginRedirectTest)
+}
+
+func basicAuthTest(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if user, pass, ok := r.BasicAuth(); ok && user == "got" && pass == "secret" {
+			http.ServeFile(w, r, "go.mod")
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", `Basic realm="got"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	downloadWithAuth(t, srv.URL, &got.Auth{Username: "got", Password: "secret"}, true)
+	downloadWithAuth(t, srv.URL, &got.Auth{Username: "got", Password: "wrong"}, false)
+}
+
+func digestAuthTest(t *testing.T) {
+
+	h := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		params := digestParams(r.Header.Get("Authorization"))
+
+		ha1 := h("got:testing@got:secret")
+		ha2 := h(r.Method + ":" + params["uri"])
+		expected := h(ha1 + ":abc123:" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+
+		if params["response"] == expected && params["opaque"] == "xyz" && params["uri"] == r.URL.RequestURI() {
+			http.ServeFile(w, r, "go.mod")
+			return
+		}
+
+		w.Header().Add("WWW-Authenticate", `Digest realm="testing@got", qop="auth", algorithm=MD5, nonce="abc123", opaque="xyz"`)
+		w.Header().Add("WWW-Authenticate", `Digest realm="testing@got", qop="auth, auth-int", algorithm=SHA-256, nonce="abc123", opaque="xyz"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	downloadWithAuth(t, srv.URL+"/file?q=1", &got.Auth{Username: "got", Password: "secret"}, true)
+	downloadWithAuth(t, srv.URL+"/file?q=1", &got.Auth{Username: "got", Password: "wrong"}, false)
+}
+
+func bearerAuthTest(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Authorization") == "Bearer token" {
+			http.ServeFile(w, r, "go.mod")
+			return
+		}
+
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	downloadWithAuth(t, srv.URL, &got.Auth{Token: "token"}, true)
+}
+
+func netrcAuthTest(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if user, pass, ok := r.BasicAuth(); ok && user == "got" && pass == "netrc" {
+			http.ServeFile(w, r, "go.mod")
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", `Basic realm="got"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	netrc := filepath.Join(dir, ".netrc")
+	data := "machine example.com login foo password bar\nmachine 127.0.0.1\n\tlogin got\n\tpassword netrc\n"
+
+	if err := os.WriteFile(netrc, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	downloadWithAuth(t, srv.URL, &got.Auth{Netrc: true, NetrcFile: netrc}, true)
+}
+
+func crossOriginRedirectTest(t *testing.T) {
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Authorization") != "" || r.Header.Get("X-Token") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		http.ServeFile(w, r, "go.mod")
+	}))
+	defer other.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/go.mod", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	tmpFile := createTemp()
+	defer clean(tmpFile)
+
+	dl := got.NewDownload(context.Background(), srv.URL, tmpFile)
+	dl.Auth = &got.Auth{Token: "token"}
+	dl.Header = []got.GotHeader{
+		{Key: "Authorization", Value: "Bearer raw"},
+		{Key: "X-Token", Value: "public"},
+	}
+
+	if err := dl.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := dl.Start(); err != nil {
+		t.Error(err)
+	}
+}
+
+func downloadWithAuth(t *testing.T, URL string, auth *got.Auth, ok bool) {
+
+	tmpFile := createTemp()
+	defer clean(tmpFile)
+
+	g := got.New()
+	g.Auth = auth
+
+	dl := &got.Download{
+		URL:       URL,
+		Dest:      tmpFile,
+		ChunkSize: 50,
+	}
+
+	err := g.Do(dl)
+
+	if ok && err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !ok && err == nil {
+		t.Errorf("Expecting unauthorized error with %+v", auth)
+		return
+	}
+
+	if ok {
+
+		stat, err := os.Stat(tmpFile)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if stat.Size() != okFileStat.Size() {
+			t.Errorf("Expecting size: %d, but got %d", okFileStat.Size(), stat.Size())
+		}
+	}
+}
+
+func digestParams(header string) map[string]string {
+
+	params := make(map[string]string)
+
+	for _, p := range strings.Split(strings.TrimPrefix(header, "Digest "), ", ") {
+
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+
+	return params
+}
+
+func ExampleAuth() {
+
+	// Just for testing
+	destPath := createTemp()
+	defer clean(destPath)
+
+	g := got.New()
+
+	g.Auth = &got.Auth{
+		Username: "user",
+		Password: "secret",
+	}
+
+	err := g.Download(testUrl, destPath)
+
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println("done")
+
+	// Output: done
+}
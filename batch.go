@@ -0,0 +1,146 @@
+package got
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// BatchConfig holds the options of batch downloads.
+type BatchConfig struct {
+
+	// Parallel is the number of files downloaded concurrently, defaults to 4.
+	Parallel uint
+
+	// KeepGoing starts the next downloads after a failed download,
+	// otherwise the batch stops at the first error.
+	KeepGoing bool
+
+	// MaxFailures stops a KeepGoing batch after this number of failed downloads, 0 is unlimited.
+	MaxFailures uint
+
+	// Do downloads a batch download, defaults to Got.Do, e.g. to try mirrors or verify a checksum.
+	Do func(d *Download) error
+
+	// Done is called when a download is done.
+	Done func(r BatchResult)
+}
+
+// BatchResult is the result of a batch download.
+type BatchResult struct {
+	Download *Download
+
+	// Err is the download error, ErrBatchStopped for the downloads not started.
+	Err error
+}
+
+// ErrBatchStopped is the result error of the downloads not started when the batch stops.
+var ErrBatchStopped = errors.New("Batch stopped before the download")
+
+// ErrBatchFailed is returned when downloads of a KeepGoing batch fail.
+var ErrBatchFailed = errors.New("Batch downloads failed")
+
+// DownloadBatch downloads the files concurrently and returns a result per download, in the same order.
+// It returns the first download error, or ErrBatchFailed when KeepGoing is set and some downloads failed.
+func (g Got) DownloadBatch(downloads []*Download, c *BatchConfig) ([]BatchResult, error) {
+
+	if c == nil {
+		c = &BatchConfig{}
+	}
+
+	parallel := c.Parallel
+	if parallel == 0 {
+		parallel = 4
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		slots   = make(chan struct{}, parallel)
+		results = make([]BatchResult, len(downloads))
+		ctx     = g.ctx
+
+		failed uint
+		err    error
+	)
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	do := c.Do
+	if do == nil {
+		do = g.Do
+	}
+
+	// stopped reports whether the batch stops starting downloads.
+	stopped := func() bool {
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !c.KeepGoing {
+			return err != nil
+		}
+
+		return c.MaxFailures > 0 && failed >= c.MaxFailures
+	}
+
+	for i, d := range downloads {
+
+		results[i] = BatchResult{Download: d, Err: ErrBatchStopped}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
+		if stopped() {
+			<-slots
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(i int, d *Download) {
+
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			derr := do(d)
+
+			mu.Lock()
+
+			results[i].Err = derr
+
+			if derr != nil {
+
+				failed++
+
+				if err == nil {
+					err = fmt.Errorf("%s: %w", d.URL, derr)
+				}
+			}
+
+			mu.Unlock()
+
+			if c.Done != nil {
+				c.Done(results[i])
+			}
+		}(i, d)
+	}
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return results, ctx.Err()
+	}
+
+	if failed > 0 && c.KeepGoing {
+		return results, fmt.Errorf("%w: %d of %d", ErrBatchFailed, failed, len(downloads))
+	}
+
+	return results, err
+}
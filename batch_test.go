@@ -0,0 +1,155 @@
+package got_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/melbahja/got"
+)
+
+func TestDownloadBatch(t *testing.T) {
+
+	t.Run("stopFirst", batchStopFirstTest)
+	t.Run("keepGoing", batchKeepGoingTest)
+	t.Run("maxFailures", batchMaxFailuresTest)
+	t.Run("do", batchDoTest)
+}
+
+func batchDownloads(t *testing.T, paths ...string) []*got.Download {
+
+	dir := t.TempDir()
+	downloads := make([]*got.Download, len(paths))
+
+	for i, p := range paths {
+		downloads[i] = &got.Download{URL: httpt.URL + p, Dir: dir, Dest: fmt.Sprintf("%d_%s", i, path.Base(p))}
+	}
+
+	return downloads
+}
+
+func batchStopFirstTest(t *testing.T) {
+
+	downloads := batchDownloads(t, "/ok_file", "/not_found", "/ok_file")
+
+	results, err := got.New().DownloadBatch(downloads, &got.BatchConfig{Parallel: 1})
+
+	if err == nil || errors.Is(err, got.ErrBatchFailed) {
+		t.Fatalf("Expecting the download error, got: %v", err)
+	}
+
+	if results[0].Err != nil || results[1].Err == nil || results[2].Err != got.ErrBatchStopped {
+		t.Errorf("Unexpected results: %v, %v, %v", results[0].Err, results[1].Err, results[2].Err)
+	}
+
+	if _, err := os.Stat(downloads[2].Path()); err == nil {
+		t.Error("Expecting the stopped download file to not exist")
+	}
+}
+
+func batchKeepGoingTest(t *testing.T) {
+
+	var (
+		mu   sync.Mutex
+		done int
+	)
+
+	downloads := batchDownloads(t, "/not_found", "/ok_file", "/not_found", "/ok_file")
+
+	results, err := got.New().DownloadBatch(downloads, &got.BatchConfig{
+		Parallel:  2,
+		KeepGoing: true,
+		Done: func(r got.BatchResult) {
+			mu.Lock()
+			done++
+			mu.Unlock()
+		},
+	})
+
+	if !errors.Is(err, got.ErrBatchFailed) {
+		t.Fatalf("Expecting ErrBatchFailed, got: %v", err)
+	}
+
+	if done != 4 {
+		t.Errorf("Expecting 4 done calls, got: %d", done)
+	}
+
+	for i, r := range results {
+
+		if r.Download != downloads[i] {
+			t.Errorf("Expecting result %d of its download", i)
+		}
+
+		if (i%2 == 0) != (r.Err != nil) {
+			t.Errorf("Unexpected result %d error: %v", i, r.Err)
+		}
+	}
+
+	if size := results[1].Download.Size(); size != uint64(okFileStat.Size()) {
+		t.Errorf("Expecting size %d, got: %d", okFileStat.Size(), size)
+	}
+}
+
+func batchMaxFailuresTest(t *testing.T) {
+
+	downloads := batchDownloads(t, "/not_found", "/ok_file", "/not_found", "/ok_file")
+
+	results, err := got.New().DownloadBatch(downloads, &got.BatchConfig{
+		Parallel:    1,
+		KeepGoing:   true,
+		MaxFailures: 2,
+	})
+
+	if !errors.Is(err, got.ErrBatchFailed) {
+		t.Fatalf("Expecting ErrBatchFailed, got: %v", err)
+	}
+
+	if results[1].Err != nil || results[2].Err == nil || results[3].Err != got.ErrBatchStopped {
+		t.Errorf("Unexpected results: %v, %v, %v", results[1].Err, results[2].Err, results[3].Err)
+	}
+}
+
+func batchDoTest(t *testing.T) {
+
+	var (
+		mu   sync.Mutex
+		done = make(map[*got.Download]bool)
+		g    = got.New()
+	)
+
+	downloads := batchDownloads(t, "/not_found", "/ok_file")
+
+	// Retry the failed downloads with a mirror.
+	results, err := g.DownloadBatch(downloads, &got.BatchConfig{
+		Do: func(d *got.Download) error {
+
+			mu.Lock()
+			done[d] = true
+			mu.Unlock()
+
+			if err := g.Do(d); err == nil {
+				return nil
+			}
+
+			return g.Do(&got.Download{URL: httpt.URL + "/ok_file", Dir: d.Dir, Dest: d.Dest})
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, r := range results {
+
+		if !done[r.Download] || r.Err != nil {
+			t.Errorf("Expecting download %d done by Do, got: %v", i, r.Err)
+		}
+	}
+
+	if info, err := os.Stat(downloads[0].Path()); err != nil || info.Size() != okFileStat.Size() {
+		t.Errorf("Expecting the mirror file, got: %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// batchEntry is a batch input URL with its own options.
+type batchEntry struct {
+	URL string `json:"url"`
+
+	// Out is the output path, relative to Dir.
+	Out string `json:"out"`
+
+	Dir string `json:"dir"`
+
+	// Header values are "Key: Value" headers added to the global headers,
+	// a key replaces the global values.
+	Header []string `json:"header"`
+
+	// Checksum is the TYPE=DIGEST of the file, e.g. sha-256=hex.
+	Checksum string `json:"checksum"`
+
+	// Mirrors are tried in order when the URL download fails.
+	Mirrors []string `json:"mirrors"`
+
+	// err is the error of an entry that failed before its downloads.
+	err error
+}
+
+// checksums are the supported checksum types.
+var checksums = map[string]func() hash.Hash{
+	"md5":     md5.New,
+	"sha-1":   sha1.New,
+	"sha-224": sha256.New224,
+	"sha-256": sha256.New,
+	"sha-384": sha512.New384,
+	"sha-512": sha512.New,
+}
+
+// batchReader reads aria2 style batch input: a URL line, with tab separated mirrors,
+// followed by indented option lines (out=, dir=, header=, checksum=, mirrors=),
+// or JSON Lines entries. Empty lines and lines starting with # are skipped.
+type batchReader struct {
+	scanner *bufio.Scanner
+
+	// line is the current line number.
+	line int
+
+	// next is the entry read before the current entry options ended.
+	next *batchEntry
+}
+
+func newBatchReader(scanner *bufio.Scanner) *batchReader {
+	return &batchReader{scanner: scanner}
+}
+
+// Next returns the next entry, or io.EOF at the end of the input.
+func (b *batchReader) Next() (*batchEntry, error) {
+
+	e := b.next
+	b.next = nil
+
+	for b.scanner.Scan() {
+
+		b.line++
+
+		text := b.scanner.Text()
+		line := strings.TrimSpace(text)
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Indented option of the current entry.
+		if text[0] == ' ' || text[0] == '\t' {
+
+			if e == nil {
+				return nil, fmt.Errorf("Batch line %d: option without URL", b.line)
+			}
+
+			if err := e.set(line); err != nil {
+				return nil, fmt.Errorf("Batch line %d: %w", b.line, err)
+			}
+
+			continue
+		}
+
+		next, err := parseBatchLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("Batch line %d: %w", b.line, err)
+		}
+
+		if e == nil {
+			e = next
+			continue
+		}
+
+		b.next = next
+		return e, e.validate()
+	}
+
+	if err := b.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if e == nil {
+		return nil, io.EOF
+	}
+
+	return e, e.validate()
+}
+
+// parseBatchLine parses a JSON entry or a URL line with tab separated mirrors.
+func parseBatchLine(line string) (*batchEntry, error) {
+
+	e := &batchEntry{}
+
+	if strings.HasPrefix(line, "{") {
+
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.DisallowUnknownFields()
+
+		if err := dec.Decode(e); err != nil {
+			return nil, fmt.Errorf("Invalid JSON entry: %w", err)
+		}
+
+		if e.URL == "" {
+			return nil, fmt.Errorf("JSON entry without url")
+		}
+
+		return e, nil
+	}
+
+	urls := strings.Split(line, "\t")
+	e.URL = strings.TrimSpace(urls[0])
+
+	for _, m := range urls[1:] {
+		if m = strings.TrimSpace(m); m != "" {
+			e.Mirrors = append(e.Mirrors, m)
+		}
+	}
+
+	return e, nil
+}
+
+// set sets an indented key=value option.
+func (e *batchEntry) set(option string) error {
+
+	key, value, ok := strings.Cut(option, "=")
+	if !ok {
+		return fmt.Errorf("Invalid option %s", option)
+	}
+
+	value = strings.TrimSpace(value)
+
+	switch strings.TrimSpace(key) {
+	case "out":
+		e.Out = value
+	case "dir":
+		e.Dir = value
+	case "header":
+		e.Header = append(e.Header, value)
+	case "checksum":
+		e.Checksum = value
+	case "mirrors":
+		e.Mirrors = append(e.Mirrors, strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})...)
+	default:
+		return fmt.Errorf("Unknown option %s", key)
+	}
+
+	return nil
+}
+
+// validate checks the entry headers and checksum.
+func (e *batchEntry) validate() error {
+
+	if _, err := parseHeaders(e.Header); err != nil {
+		return fmt.Errorf("%s: %w", e.URL, err)
+	}
+
+	if e.Checksum != "" {
+		if _, _, err := parseChecksum(e.Checksum); err != nil {
+			return fmt.Errorf("%s: %w", e.URL, err)
+		}
+	}
+
+	return nil
+}
+
+// requestHeader returns the global request headers with the entry headers.
+func (e *batchEntry) requestHeader(global http.Header) http.Header {
+
+	if len(e.Header) == 0 {
+		return global
+	}
+
+	header := global.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	values, _ := parseHeaders(e.Header)
+
+	for key, v := range values {
+		header[key] = v
+	}
+
+	return header
+}
+
+// parseChecksum parses a TYPE=DIGEST checksum.
+func parseChecksum(checksum string) (func() hash.Hash, []byte, error) {
+
+	typ, digest, ok := strings.Cut(checksum, "=")
+	if !ok {
+		return nil, nil, fmt.Errorf("Invalid checksum %s, expecting TYPE=DIGEST", checksum)
+	}
+
+	newHash, err := checksumHash(typ)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sum, err := hex.DecodeString(strings.TrimSpace(digest))
+	if err != nil || len(sum) != newHash().Size() {
+		return nil, nil, fmt.Errorf("Invalid %s digest %s", typ, digest)
+	}
+
+	return newHash, sum, nil
+}
+
+// checksumHash returns the hash of a checksum type, the type dash is optional.
+func checksumHash(typ string) (func() hash.Hash, error) {
+
+	typ = strings.ToLower(strings.TrimSpace(typ))
+
+	if strings.HasPrefix(typ, "sha") && !strings.HasPrefix(typ, "sha-") {
+		typ = "sha-" + typ[3:]
+	}
+
+	newHash, ok := checksums[typ]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported checksum type %s", typ)
+	}
+
+	return newHash, nil
+}
+
+// verifyChecksum checks the file against a TYPE=DIGEST checksum.
+func verifyChecksum(path, checksum string) error {
+
+	newHash, sum, err := parseChecksum(checksum)
+	if err != nil {
+		return err
+	}
+
+	actual, err := fileHash(path, newHash)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(actual, sum) {
+		return fmt.Errorf("%s checksum mismatch: expected %x, got %x", path, sum, actual)
+	}
+
+	return nil
+}
+
+// fileChecksum returns the hex digest of the file with the checksum type.
+func fileChecksum(path, typ string) (string, error) {
+
+	newHash, err := checksumHash(typ)
+	if err != nil {
+		return "", err
+	}
+
+	sum, err := fileHash(path, newHash)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(sum), nil
+}
+
+func fileHash(path string, newHash func() hash.Hash) ([]byte, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	h := newHash()
+
+	if _, err = io.Copy(h, file); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandGlob(t *testing.T) {
+
+	tests := []struct {
+		URL  string
+		urls []string
+	}{
+		{"http://a.com/f", []string{"http://a.com/f"}},
+		{"http://a.com/{x,y}.txt", []string{"http://a.com/x.txt", "http://a.com/y.txt"}},
+		{"http://a.com/[1-3]", []string{"http://a.com/1", "http://a.com/2", "http://a.com/3"}},
+		{"http://a.com/[08-10]", []string{"http://a.com/08", "http://a.com/09", "http://a.com/10"}},
+		{"http://a.com/[1-10:4]", []string{"http://a.com/1", "http://a.com/5", "http://a.com/9"}},
+		{"http://a.com/[a-c:2]", []string{"http://a.com/a", "http://a.com/c"}},
+		{"http://a.com/{a,b}[1-2]", []string{"http://a.com/a1", "http://a.com/a2", "http://a.com/b1", "http://a.com/b2"}},
+		{`http://a.com/\[1-2\]\{x\}`, []string{"http://a.com/[1-2]{x}"}},
+		{"http://[::1]:8080/[1-2]", []string{"http://[::1]:8080/1", "http://[::1]:8080/2"}},
+		{"http://a.com/[18446744073709551614-18446744073709551615]", []string{"http://a.com/18446744073709551614", "http://a.com/18446744073709551615"}},
+		{"http://a.com/[18446744073709551610-18446744073709551615:3]", []string{"http://a.com/18446744073709551610", "http://a.com/18446744073709551613"}},
+		{"http://a.com/[a-z:9223372036854775807]", []string{"http://a.com/a"}},
+		{"http://a.com/[18446744073709551615-18446744073709551615:100]", []string{"http://a.com/18446744073709551615"}},
+
+		// Malformed globs.
+		{"http://a.com/[1-3", nil},
+		{"http://a.com/{a,b", nil},
+		{"http://a.com/1]", nil},
+		{"http://a.com/a}", nil},
+		{"http://a.com/[3-1]", nil},
+		{"http://a.com/[1-]", nil},
+		{"http://a.com/[-1]", nil},
+		{"http://a.com/[1]", nil},
+		{"http://a.com/[1-3:0]", nil},
+		{"http://a.com/[1-3:-1]", nil},
+		{"http://a.com/[1-3:x]", nil},
+		{"http://a.com/[a-Z]", nil},
+		{"http://a.com/[c-a]", nil},
+		{"http://a.com/[x-18446744073709551616]", nil},
+		{"http://a.com/[1-18446744073709551616]", nil},
+		{"http://a.com/[0-100000]", nil},
+		{"http://a.com/[1-1000][1-1000]", nil},
+	}
+
+	for _, test := range tests {
+
+		urls, err := expandGlob(test.URL)
+
+		if test.urls == nil {
+
+			if err == nil {
+				t.Errorf("Expecting %s error, got: %d URLs", test.URL, len(urls))
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected %s error: %v", test.URL, err)
+			continue
+		}
+
+		got := make([]string, len(urls))
+		for i, u := range urls {
+			got[i] = u.URL
+		}
+
+		if !reflect.DeepEqual(got, test.urls) {
+			t.Errorf("Expecting %s URLs %v, got: %v", test.URL, test.urls, got)
+		}
+	}
+}
+
+func TestGlobOutput(t *testing.T) {
+
+	u := globURL{values: []string{"a", "01"}}
+
+	tests := map[string]string{
+		"#1_#2.txt": "a_01.txt",
+		"#2#1":      "01a",
+		"#3.txt":    "#3.txt",
+		"#0#":       "#0#",
+		"##1":       "#a",
+		"file":      "file",
+	}
+
+	for name, expected := range tests {
+
+		if out := u.Output(name); out != expected {
+			t.Errorf("Expecting output %s of %s, got: %s", expected, name, out)
+		}
+	}
+}
@@ -2,27 +2,42 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
 	"strings"
+	"sync/atomic"
 	"syscall"
 
-	"github.com/dustin/go-humanize"
 	"github.com/melbahja/got"
 	"github.com/urfave/cli/v2"
-	"gitlab.com/poldi1405/go-ansi"
-	"gitlab.com/poldi1405/go-indicators/progress"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
 var version string
 
-var HeaderSlice []got.GotHeader
+var RequestHeader http.Header
+
+var Body []byte
+
+var HLS *got.HLSConfig
+
+var DASH *got.DASHConfig
+
+var Progress reporter
+
+var Config *config
+
+var OnConflict got.ConflictPolicy
+
+var ServerName got.ServerNamePolicy
 
 func main() {
 
@@ -40,16 +55,80 @@ func main() {
 		log.Fatal(got.ErrDownloadAborted)
 	}()
 
-	// CLI app.
-	app := &cli.App{
+	if err := newApp(ctx).Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newApp returns the CLI app running the downloads with ctx.
+func newApp(ctx context.Context) *cli.App {
+
+	// -v is the verbose flag.
+	cli.VersionFlag = &cli.BoolFlag{
+		Name:  "version",
+		Usage: "print the version",
+	}
+
+	return &cli.App{
 		Name:  "Got",
 		Usage: "The fastest http downloader.",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:    "output",
-				Usage:   "Download `path`, if dir passed the path witll be `dir + output`.",
+				Usage:   "Download `path`, if dir passed the path witll be `dir + output`, #N is replaced with the Nth URL glob value.",
 				Aliases: []string{"o"},
 			},
+			&cli.BoolFlag{
+				Name:    "verbose",
+				Usage:   "Log the probes, retries and errors to stderr.",
+				Aliases: []string{"v"},
+			},
+			&cli.BoolFlag{
+				Name:    "debug",
+				Usage:   "Log the requests and chunks too.",
+				Aliases: []string{"vv"},
+			},
+			&cli.BoolFlag{
+				Name:    "quiet",
+				Usage:   "Print the failures only.",
+				Aliases: []string{"q"},
+			},
+			&cli.StringFlag{
+				Name:  "on-conflict",
+				Usage: "Action when the file exists: overwrite, skip, skip-same-size, rename or fail.",
+				Value: "overwrite",
+			},
+			&cli.StringFlag{
+				Name:  "server-name",
+				Usage: "Use of the server Content-Disposition file name: prefer, fallback (when the URL has no name) or ignore.",
+				Value: "prefer",
+			},
+			&cli.UintFlag{
+				Name:  "parallel",
+				Usage: "Number of `files` downloaded at once, recursive and spider downloads default to 4.",
+				Value: 1,
+			},
+			&cli.BoolFlag{
+				Name:  "keep-going",
+				Usage: "Continue with the next downloads when a download fails.",
+			},
+			&cli.UintFlag{
+				Name:  "max-failures",
+				Usage: "Stop a --keep-going run after this `number` of failed downloads, 0 is unlimited.",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print newline delimited JSON events to stderr instead of the progress.",
+			},
+			&cli.StringFlag{
+				Name:  "json-file",
+				Usage: "Write newline delimited JSON events to a `file` instead of the progress.",
+			},
+			&cli.BoolFlag{
+				Name:    "globoff",
+				Usage:   "Disable the {a,b} and [1-10] URL globs.",
+				Aliases: []string{"g"},
+			},
 			&cli.StringFlag{
 				Name:    "dir",
 				Usage:   "Save downloaded file to a `directory`.",
@@ -70,16 +149,177 @@ func main() {
 				Usage:   "Chunks that will be downloaded concurrently.",
 				Aliases: []string{"c"},
 			},
-			&cli.StringSliceFlag{
+			&cli.StringFlag{
+				Name:    "request",
+				Usage:   "Request `method`, defaults to GET, or POST when data is set.",
+				Aliases: []string{"X"},
+			},
+			&cli.StringFlag{
+				Name:  "data",
+				Usage: "Send the `data` in a request body, @file reads it from a file and strips the newlines.",
+			},
+			&cli.StringFlag{
+				Name:  "data-binary",
+				Usage: "Send the `data` in a request body as is, @file reads it from a file.",
+			},
+			&cli.GenericFlag{
 				Name:    "header",
-				Usage:   `Set these HTTP-Headers on the requests. The format has to be: -H "Key: Value"`,
+				Usage:   `Add HTTP-Headers to the requests: -H "Key: Value", repeat it for many values, -H "Key:" removes a default header and -H "Key;" sends an empty value.`,
 				Aliases: []string{"H"},
+				Value:   &headerFlag{},
 			},
 			&cli.StringFlag{
 				Name:    "agent",
 				Usage:   `Set user agent for got HTTP requests.`,
 				Aliases: []string{"u"},
 			},
+			&cli.IntFlag{
+				Name:        "max-redirects",
+				Usage:       "Maximum `number` of redirects to follow, -1 to disable redirects.",
+				DefaultText: "10",
+			},
+			&cli.BoolFlag{
+				Name:  "allow-downgrade",
+				Usage: "Allow redirects from https to http.",
+			},
+			&cli.StringFlag{
+				Name:  "user",
+				Usage: "Server `user:password` for Basic or Digest auth, password is prompted if omitted.",
+			},
+			&cli.BoolFlag{
+				Name:  "netrc",
+				Usage: "Read credentials from ~/.netrc file.",
+			},
+			&cli.StringFlag{
+				Name:  "proxy",
+				Usage: "Proxy `URL` of the requests, defaults to the HTTP_PROXY and HTTPS_PROXY env vars.",
+			},
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "Config `file`, defaults to $XDG_CONFIG_HOME/got/config.toml.",
+			},
+			&cli.StringFlag{
+				Name:  "bearer",
+				Usage: "Bearer `token` for authorization.",
+			},
+			&cli.StringFlag{
+				Name:  "load-cookies",
+				Usage: "Load cookies from Netscape cookies.txt `file`.",
+			},
+			&cli.StringFlag{
+				Name:  "save-cookies",
+				Usage: "Save cookies to Netscape cookies.txt `file` when done.",
+			},
+			&cli.StringFlag{
+				Name:  "cacert",
+				Usage: "CA certificates PEM `file` to verify the server.",
+			},
+			&cli.StringFlag{
+				Name:  "cert",
+				Usage: "Client certificate PEM `file` for mutual TLS.",
+			},
+			&cli.StringFlag{
+				Name:  "key",
+				Usage: "Client private key PEM `file`, defaults to --cert file.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "pinnedpubkey",
+				Usage: "Pin the server public key, `sha256//base64` hashes separated by \";\" or a public key file.",
+			},
+			&cli.BoolFlag{
+				Name:    "insecure",
+				Usage:   "Skip the server certificate verification.",
+				Aliases: []string{"k"},
+			},
+			&cli.StringFlag{
+				Name:  "s3-endpoint",
+				Usage: "S3 compatible server `URL` for s3:// downloads, e.g. http://localhost:9000.",
+			},
+			&cli.StringFlag{
+				Name:  "s3-region",
+				Usage: "S3 `region`, defaults to $AWS_REGION or the AWS config region.",
+			},
+			&cli.StringFlag{
+				Name:  "profile",
+				Usage: "AWS shared config and credentials `profile` for s3:// downloads.",
+			},
+			&cli.BoolFlag{
+				Name:  "s3-path-style",
+				Usage: "Use path style S3 URLs instead of virtual hosted style.",
+			},
+			&cli.BoolFlag{
+				Name:    "recursive",
+				Usage:   "Download the files of an HTTP directory listing and its subdirectories.",
+				Aliases: []string{"r"},
+			},
+			&cli.BoolFlag{
+				Name:  "webdav",
+				Usage: "Download the files of a WebDAV collection and its sub collections.",
+			},
+			&cli.BoolFlag{
+				Name:  "skip-unchanged",
+				Usage: "Recursive and WebDAV downloads skip the files with an unchanged ETag.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "include",
+				Usage: "Recursive download only the files matching the `glob` patterns.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "exclude",
+				Usage: "Recursive download skips the files and directories matching the `glob` patterns.",
+			},
+			&cli.IntFlag{
+				Name:  "depth",
+				Usage: "Maximum directory or link `levels` of recursive and spider downloads, 0 is unlimited.",
+			},
+			&cli.BoolFlag{
+				Name:  "spider",
+				Usage: "Follow the links of the HTML pages and download the accepted files.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "domains",
+				Usage: "Spider follows the links to these `domains` and their subdomains, defaults to the URL host.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "accept",
+				Usage: "Spider downloads only the files matching the extensions, MIME types or glob `patterns`.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "reject",
+				Usage: "Spider skips the files matching the extensions, MIME types or glob `patterns`.",
+			},
+			&cli.DurationFlag{
+				Name:  "wait",
+				Usage: "Spider waits this `duration` between the requests to the same host.",
+			},
+			&cli.BoolFlag{
+				Name:  "ignore-robots",
+				Usage: "Spider ignores the robots.txt rules.",
+			},
+			&cli.BoolFlag{
+				Name:  "hls",
+				Usage: "Download the URL as an HLS m3u8 playlist into one file.",
+			},
+			&cli.Uint64Flag{
+				Name:  "hls-bandwidth",
+				Usage: "Select the HLS variant with the highest bandwidth up to `bps`.",
+			},
+			&cli.StringFlag{
+				Name:  "hls-resolution",
+				Usage: "Select the HLS variant with the `resolution`, e.g. 1280x720.",
+			},
+			&cli.StringFlag{
+				Name:  "hls-key",
+				Usage: "AES-128 `key` of HLS segments, in hex or @file with the raw key.",
+			},
+			&cli.BoolFlag{
+				Name:  "dash",
+				Usage: "Download the URL as an MPEG-DASH manifest, each track into its own file.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "dash-rep",
+				Usage: "DASH representation `ids` to download, defaults to the best video and audio.",
+			},
 		},
 		Version: version,
 		Authors: []*cli.Author{
@@ -92,52 +332,37 @@ func main() {
 			return run(ctx, c)
 		},
 	}
-
-	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
-	}
 }
 
-func run(ctx context.Context, c *cli.Context) error {
+func run(ctx context.Context, c *cli.Context) (err error) {
 
-	var (
-		g *got.Got           = got.NewWithContext(ctx)
-		p *progress.Progress = new(progress.Progress)
-	)
+	// Set the flags from the env and the config file.
+	if Config, err = loadConfig(c); err != nil {
+		return err
+	}
 
-	// Set progress style.
-	p.SetStyle(progressStyle)
+	g := got.NewWithContext(ctx)
 
-	// Progress func.
-	g.ProgressFunc = func(d *got.Download) {
+	// Progress lines, or JSON events.
+	if Progress, err = getReporter(c); err != nil {
+		return err
+	}
 
-		// 55 is just an estimation of the text showed with the progress.
-		// it's working fine with $COLUMNS >= 47
-		p.Width = getWidth() - 55
+	g.ProgressFunc = Progress.Update
 
-		perc, err := progress.GetPercentage(float64(d.Size()), float64(d.TotalSize()))
-		if err != nil {
-			perc = 100
-		}
-
-		var bar string
-		if getWidth() <= 46 {
-			bar = ""
-		} else {
-			bar = r + color(p.GetBar(perc, 100)) + l
-		}
-
-		fmt.Printf(
-			" %6.2f%% %s %s/%s @ %s/s%s\r",
-			perc,
-			bar,
-			humanize.Bytes(d.Size()),
-			humanize.Bytes(d.TotalSize()),
-			humanize.Bytes(d.Speed()),
-			ansi.ClearRight(),
-		)
+	// Log lines are written through the reporter.
+	switch {
+	case c.Bool("quiet"):
+	case c.Bool("debug"):
+		g.Logger = got.NewTextLogger(Progress, got.LogDebug)
+	case c.Bool("verbose"):
+		g.Logger = got.NewTextLogger(Progress, got.LogInfo)
 	}
 
+	defer func() {
+		err = Progress.Finish(err)
+	}()
+
 	info, err := os.Stdin.Stat()
 
 	if err != nil {
@@ -157,14 +382,100 @@ func run(ctx context.Context, c *cli.Context) error {
 		got.UserAgent = c.String("agent")
 	}
 
-	// Piped stdin
-	if info.Mode()&os.ModeNamedPipe > 0 || info.Size() > 0 {
+	// Set existing files policy.
+	if OnConflict, err = got.ParseConflictPolicy(c.String("on-conflict")); err != nil {
+		return err
+	}
+
+	// Set server file names policy.
+	if ServerName, err = got.ParseServerNamePolicy(c.String("server-name")); err != nil {
+		return err
+	}
 
-		if err := multiDownload(ctx, c, g, bufio.NewScanner(os.Stdin)); err != nil {
+	// Set request headers.
+	if RequestHeader, err = parseHeaders(*c.Generic("header").(*headerFlag)); err != nil {
+		return err
+	}
+
+	// Set request body.
+	if Body, err = getBody(c); err != nil {
+		return err
+	}
+
+	// Set HLS options.
+	if HLS, err = getHLS(c); err != nil {
+		return err
+	}
+
+	// Set DASH options.
+	if c.Bool("dash") {
+		DASH = &got.DASHConfig{Representations: c.StringSlice("dash-rep")}
+	}
+
+	// Set authentication.
+	if g.Auth, err = getAuth(c); err != nil {
+		return err
+	}
+
+	// Set proxy.
+	if c.String("proxy") != "" {
+		if g.Client, err = proxyClient(c.String("proxy")); err != nil {
 			return err
 		}
 	}
 
+	// Set TLS options.
+	if c.String("cacert") != "" || c.String("cert") != "" || c.StringSlice("pinnedpubkey") != nil || c.Bool("insecure") {
+
+		g.TLS = &got.TLSConfig{
+			CACert:        c.String("cacert"),
+			ClientCert:    c.String("cert"),
+			ClientKey:     c.String("key"),
+			PinnedPubKeys: c.StringSlice("pinnedpubkey"),
+			Insecure:      c.Bool("insecure"),
+		}
+	}
+
+	// Set S3 options, credentials are read from the AWS env and shared files.
+	g.S3 = &got.S3Config{
+		Endpoint: c.String("s3-endpoint"),
+		Region:   c.String("s3-region"),
+		Profile:  c.String("profile"),
+	}
+
+	if c.Bool("s3-path-style") {
+		g.S3.Addressing = got.S3PathStyle
+	}
+
+	// Set cookies jar.
+	if c.String("load-cookies") != "" || c.String("save-cookies") != "" {
+
+		jar := got.NewCookieJar()
+
+		if c.String("load-cookies") != "" {
+			if err = jar.LoadFile(c.String("load-cookies")); err != nil {
+				return err
+			}
+		}
+
+		if c.String("save-cookies") != "" {
+			defer func() {
+				if serr := jar.SaveFile(c.String("save-cookies")); serr != nil && err == nil {
+					err = serr
+				}
+			}()
+		}
+
+		g.Jar = jar
+	}
+
+	q := newQueue()
+
+	// Piped stdin
+	if info.Mode()&os.ModeNamedPipe > 0 || info.Size() > 0 {
+		q.read(c, bufio.NewScanner(os.Stdin))
+	}
+
 	// Batch file.
 	if c.String("file") != "" {
 
@@ -174,35 +485,45 @@ func run(ctx context.Context, c *cli.Context) error {
 			return err
 		}
 
-		if err := multiDownload(ctx, c, g, bufio.NewScanner(file)); err != nil {
-			return err
-		}
-	}
+		defer file.Close()
 
-	if c.StringSlice("header") != nil {
-		header := c.StringSlice("header")
-
-		for _, h := range header {
-			split := strings.SplitN(h, ":", 2)
-			if len(split) == 1 {
-				return errors.New("malformatted header " + h)
-			}
-			HeaderSlice = append(HeaderSlice, got.GotHeader{Key: split[0], Value: strings.TrimSpace(split[1])})
-		}
+		q.read(c, bufio.NewScanner(file))
 	}
 
 	// Download from args.
 	for _, url := range c.Args().Slice() {
+		q.add(c, &batchEntry{URL: url, Out: c.String("output")})
+	}
 
-		if err = download(ctx, c, g, url); err != nil {
-			return err
+	_, err = g.DownloadBatch(q.downloads, &got.BatchConfig{
+		Parallel:    c.Uint("parallel"),
+		KeepGoing:   c.Bool("keep-going"),
+		MaxFailures: c.Uint("max-failures"),
+		Do: func(d *got.Download) error {
+			return download(ctx, c, g, q.entries[d])
+		},
+	})
+
+	return err
+}
+
+func getReporter(c *cli.Context) (reporter, error) {
+
+	if c.String("json-file") != "" {
+
+		file, err := os.Create(c.String("json-file"))
+		if err != nil {
+			return nil, err
 		}
 
-		fmt.Print(ansi.ClearLine())
-		fmt.Println(fmt.Sprintf("✔ %s", url))
+		return newJSONReporter(file, file), nil
 	}
 
-	return nil
+	if c.Bool("json") {
+		return newJSONReporter(os.Stderr, nil), nil
+	}
+
+	return newProgressBoard(c.Bool("quiet")), nil
 }
 
 func getWidth() int {
@@ -214,42 +535,313 @@ func getWidth() int {
 	return 80
 }
 
-func multiDownload(ctx context.Context, c *cli.Context, g *got.Got, scanner *bufio.Scanner) error {
+func getBody(c *cli.Context) (body []byte, err error) {
+
+	data, binary := c.String("data"), c.String("data-binary")
+
+	switch {
+	case binary != "":
+		if strings.HasPrefix(binary, "@") {
+			return os.ReadFile(binary[1:])
+		}
+		body = []byte(binary)
+	case data != "":
+		if strings.HasPrefix(data, "@") {
+			if body, err = os.ReadFile(data[1:]); err != nil {
+				return nil, err
+			}
+			body = bytes.ReplaceAll(bytes.ReplaceAll(body, []byte("\r"), nil), []byte("\n"), nil)
+		} else {
+			body = []byte(data)
+		}
+	default:
+		return nil, nil
+	}
+
+	// Default form content type like curl.
+	if _, ok := RequestHeader["Content-Type"]; !ok && data != "" {
+		RequestHeader.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	return body, nil
+}
+
+func getMethod(c *cli.Context) string {
+
+	if c.String("request") != "" {
+		return strings.ToUpper(c.String("request"))
+	}
+
+	if c.String("data") != "" || c.String("data-binary") != "" {
+		return http.MethodPost
+	}
+
+	return http.MethodGet
+}
+
+func getHLS(c *cli.Context) (*got.HLSConfig, error) {
+
+	if !c.Bool("hls") {
+		return nil, nil
+	}
+
+	config := &got.HLSConfig{
+		Bandwidth:  c.Uint64("hls-bandwidth"),
+		Resolution: c.String("hls-resolution"),
+	}
+
+	if key := c.String("hls-key"); strings.HasPrefix(key, "@") {
+
+		data, err := os.ReadFile(key[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		config.Key = data
+
+	} else if key != "" {
+
+		data, err := hex.DecodeString(strings.TrimPrefix(key, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid HLS key: %w", err)
+		}
+
+		config.Key = data
+	}
+
+	return config, nil
+}
+
+func getAuth(c *cli.Context) (*got.Auth, error) {
+
+	if c.String("user") == "" && c.String("bearer") == "" && c.Bool("netrc") == false {
+		return nil, nil
+	}
+
+	auth := &got.Auth{
+		Token: c.String("bearer"),
+		Netrc: c.Bool("netrc"),
+	}
+
+	if c.String("user") != "" {
+
+		split := strings.SplitN(c.String("user"), ":", 2)
+		auth.Username = split[0]
+
+		if len(split) == 2 {
+			auth.Password = split[1]
+			return auth, nil
+		}
+
+		fmt.Fprintf(os.Stderr, "Enter password for user '%s': ", auth.Username)
+
+		password, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+
+		if err != nil {
+			return nil, err
+		}
+
+		auth.Password = string(password)
+	}
+
+	return auth, nil
+}
+
+// download downloads the entry URL, the mirrors are tried in order when it fails.
+func download(ctx context.Context, c *cli.Context, g *got.Got, e *batchEntry) (err error) {
 
-	for scanner.Scan() {
+	var d *got.Download
 
-		url := strings.TrimSpace(scanner.Text())
+	if e.err != nil {
+		Progress.Done(&got.Download{URL: e.URL}, e.URL, e.err)
+		return e.err
+	}
 
-		if url == "" {
-			continue
+	for i, url := range append([]string{e.URL}, e.Mirrors...) {
+
+		if i > 0 {
+			Progress.Retry(d, e.URL, err, url)
+		}
+
+		if d, err = downloadURL(ctx, c, g, e, url); err == nil || ctx.Err() != nil {
+			break
 		}
+	}
 
-		if err := download(ctx, c, g, url); err != nil {
+	// Failed recursive downloads and spiders report their own errors.
+	if d == nil {
+
+		if err == nil || multiFile(c) {
 			return err
 		}
 
-		fmt.Print(ansi.ClearLine())
-		fmt.Println(fmt.Sprintf("✔ %s", url))
+		d = &got.Download{URL: e.URL}
+	}
+
+	if err == nil && d.Skipped() {
+		Progress.Skip(d, d.Path())
+		return nil
 	}
 
-	return nil
+	Progress.Done(d, e.URL, err)
+
+	return err
 }
 
-func download(ctx context.Context, c *cli.Context, g *got.Got, url string) (err error) {
+// multiFile reports whether the URLs are downloaded as directory trees or spidered sites.
+func multiFile(c *cli.Context) bool {
+	return c.Bool("recursive") || c.Bool("webdav") || c.Bool("spider")
+}
+
+// downloadURL downloads a URL of the entry, it returns the download of single file URLs.
+func downloadURL(ctx context.Context, c *cli.Context, g *got.Got, e *batchEntry, url string) (d *got.Download, err error) {
 
 	if url, err = getURL(url); err != nil {
-		return err
+		return nil, err
+	}
+
+	dir := e.Dir
+	if dir == "" {
+		dir = c.String("dir")
+	} else if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
 	}
 
-	return g.Do(&got.Download{
+	if c.Bool("recursive") {
+		return nil, downloadRecursive(c, g.DownloadRecursive, url, dir)
+	}
+
+	if c.Bool("webdav") {
+		return nil, downloadRecursive(c, g.DownloadWebDAV, url, dir)
+	}
+
+	if c.Bool("spider") {
+		return nil, spider(c, g, url, dir)
+	}
+
+	d = &got.Download{
 		URL:         url,
-		Dir:         c.String("dir"),
-		Dest:        c.String("output"),
-		Header:      HeaderSlice,
+		Dir:         dir,
+		Dest:        e.Out,
 		Interval:    150,
 		ChunkSize:   c.Uint64("size"),
 		Concurrency: c.Uint("concurrency"),
+	}
+
+	setOptions(c, d)
+	d.RequestHeader = e.requestHeader(d.RequestHeader)
+
+	if err = g.Do(d); err != nil || e.Checksum == "" {
+		return d, err
+	}
+
+	return d, verifyChecksum(d.Path(), e.Checksum)
+}
+
+func downloadRecursive(c *cli.Context, fn func(URL, dir string, rc *got.RecursiveConfig) error, url, dir string) error {
+
+	var failed int32
+
+	err := fn(url, dir, &got.RecursiveConfig{
+		Include:       c.StringSlice("include"),
+		Exclude:       c.StringSlice("exclude"),
+		Depth:         c.Int("depth"),
+		SkipUnchanged: c.Bool("skip-unchanged"),
+		Parallel:      listingParallel(c),
+		Prepare: func(d *got.Download) {
+			d.Interval = 150
+			d.ChunkSize = c.Uint64("size")
+			d.Concurrency = c.Uint("concurrency")
+			setOptions(c, d)
+		},
+		Done: fileDone(&failed),
+	})
+
+	return listingDone(url, &failed, err)
+}
+
+func spider(c *cli.Context, g *got.Got, url, dir string) error {
+
+	var failed int32
+
+	err := g.Spider(url, dir, &got.SpiderConfig{
+		Depth:        c.Int("depth"),
+		Domains:      c.StringSlice("domains"),
+		Accept:       c.StringSlice("accept"),
+		Reject:       c.StringSlice("reject"),
+		Delay:        c.Duration("wait"),
+		IgnoreRobots: c.Bool("ignore-robots"),
+		Parallel:     listingParallel(c),
+		Prepare: func(d *got.Download) {
+			d.Interval = 150
+			d.ChunkSize = c.Uint64("size")
+			d.Concurrency = c.Uint("concurrency")
+			setOptions(c, d)
+		},
+		Done: fileDone(&failed),
 	})
+
+	return listingDone(url, &failed, err)
+}
+
+// listingParallel returns the --parallel files of recursive and spider downloads,
+// or 0 for the library default when it's not set.
+func listingParallel(c *cli.Context) uint {
+
+	if c.IsSet("parallel") {
+		return c.Uint("parallel")
+	}
+
+	return 0
+}
+
+// fileDone returns the Done func of recursive and spider downloads, it sets failed when a file fails.
+func fileDone(failed *int32) func(d *got.Download, err error) {
+
+	return func(d *got.Download, err error) {
+
+		Progress.Add(1)
+
+		switch {
+		case errors.Is(err, got.ErrUnchanged):
+			Progress.Skip(d, d.Dest)
+		case err == nil && d.Skipped():
+			Progress.Skip(d, d.Path())
+		case err != nil:
+			atomic.StoreInt32(failed, 1)
+			Progress.Done(d, d.URL, err)
+		default:
+			Progress.Done(d, d.Path(), nil)
+		}
+	}
+}
+
+// listingDone reports the error of the URL when it isn't a file error.
+func listingDone(url string, failed *int32, err error) error {
+
+	if err != nil && atomic.LoadInt32(failed) == 0 {
+		Progress.Add(1)
+		Progress.Done(&got.Download{URL: url}, url, err)
+	}
+
+	return err
+}
+
+// setOptions sets the download request options.
+func setOptions(c *cli.Context, d *got.Download) {
+
+	d.RequestHeader = RequestHeader
+	d.Method = getMethod(c)
+	d.Body = Body
+	d.MaxRedirects = c.Int("max-redirects")
+	d.AllowDowngrade = c.Bool("allow-downgrade")
+	d.HLS = HLS
+	d.DASH = DASH
+	d.ConflictPolicy = OnConflict
+	d.ServerName = ServerName
+
+	Config.setHostOptions(d)
 }
 
 func getURL(URL string) (string, error) {
@@ -257,6 +849,12 @@ func getURL(URL string) (string, error) {
 	u, err := url.Parse(URL)
 
 	if err != nil {
+
+		// Data URLs are not always valid URLs.
+		if strings.HasPrefix(URL, "data:") {
+			return URL, nil
+		}
+
 		return "", err
 	}
 
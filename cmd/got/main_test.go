@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRequestBody(t *testing.T) {
+
+	tests := []struct {
+		args                []string
+		method, body, ctype string
+	}{
+		{[]string{"-X", "POST", "--data", "a=b"}, "POST", "a=b", "application/x-www-form-urlencoded"},
+		{[]string{"--data", "a=b"}, "POST", "a=b", "application/x-www-form-urlencoded"},
+		{[]string{"--request", "put", "--data-binary", "a\nb"}, "PUT", "a\nb", ""},
+	}
+
+	// Keep the user config file out of the test.
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	for _, test := range tests {
+
+		var method, body, ctype string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			data, _ := io.ReadAll(r.Body)
+			method, body, ctype = r.Method, string(data), r.Header.Get("Content-Type")
+
+			w.Write([]byte("ok"))
+		}))
+
+		dir := t.TempDir()
+		args := append(append([]string{"got"}, test.args...), "-d", dir, server.URL+"/file.txt")
+
+		err := newApp(context.Background()).Run(args)
+		server.Close()
+
+		if err != nil {
+			t.Fatalf("Unexpected error of %v: %v", test.args, err)
+		}
+
+		if method != test.method || body != test.body || ctype != test.ctype {
+			t.Errorf("Expecting %s %q %q of %v, got: %s %q %q", test.method, test.body, test.ctype, test.args, method, body, ctype)
+		}
+
+		if data, err := os.ReadFile(filepath.Join(dir, "file.txt")); err != nil || string(data) != "ok" {
+			t.Errorf("Expecting the response file, got: %q, %v", data, err)
+		}
+	}
+}
+
+func TestKeepGoing(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tests := []struct {
+		args       []string
+		downloaded []string
+	}{
+		{[]string{"/missing", "/a.txt"}, nil},
+		{[]string{"--keep-going", "/missing", "/a.txt", "/[2-1].txt", "/b.txt"}, []string{"a.txt", "b.txt"}},
+		{[]string{"--keep-going", "--max-failures", "2", "/missing", "/a.txt", "/[2-1].txt", "/b.txt"}, []string{"a.txt"}},
+		{[]string{"--keep-going", "--parallel", "3", "/a.txt", "/missing", "/{b,c}.txt"}, []string{"a.txt", "b.txt", "c.txt"}},
+	}
+
+	for _, test := range tests {
+
+		dir := t.TempDir()
+		args := []string{"got", "-d", dir}
+
+		for _, arg := range test.args {
+
+			if arg[0] == '/' {
+				arg = server.URL + arg
+			}
+
+			args = append(args, arg)
+		}
+
+		if err := newApp(context.Background()).Run(args); err == nil {
+			t.Errorf("Expecting %v error", test.args)
+		}
+
+		files, _ := os.ReadDir(dir)
+
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+
+		if !reflect.DeepEqual(names, test.downloaded) {
+			t.Errorf("Expecting %v files %v, got: %v", test.args, test.downloaded, names)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func TestBatchReader(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		input   string
+		entries []batchEntry
+		err     string
+	}{
+		{
+			name:  "urls",
+			input: "http://a.com/1\n\n# comment\n  # indented comment\nhttp://a.com/2\n",
+			entries: []batchEntry{
+				{URL: "http://a.com/1"},
+				{URL: "http://a.com/2"},
+			},
+		},
+		{
+			name:  "options",
+			input: "http://a.com/1\n  out=one.bin\n\tdir = /tmp/x\n  header=X-A: 1\n  header=X-B: 2\nhttp://a.com/2\n",
+			entries: []batchEntry{
+				{URL: "http://a.com/1", Out: "one.bin", Dir: "/tmp/x", Header: []string{"X-A: 1", "X-B: 2"}},
+				{URL: "http://a.com/2"},
+			},
+		},
+		{
+			name:  "mirrors",
+			input: "http://a.com/f\thttp://b.com/f\t\thttp://c.com/f\n  mirrors=http://d.com/f, http://e.com/f\n",
+			entries: []batchEntry{
+				{URL: "http://a.com/f", Mirrors: []string{"http://b.com/f", "http://c.com/f", "http://d.com/f", "http://e.com/f"}},
+			},
+		},
+		{
+			name:  "checksums",
+			input: "http://a.com/f\n  checksum=sha-256=" + testSHA256 + "\nhttp://a.com/g\n  checksum=SHA256=" + testSHA256 + "\n",
+			entries: []batchEntry{
+				{URL: "http://a.com/f", Checksum: "sha-256=" + testSHA256},
+				{URL: "http://a.com/g", Checksum: "SHA256=" + testSHA256},
+			},
+		},
+		{
+			name:  "json",
+			input: `{"url": "http://a.com/f", "out": "f.bin", "mirrors": ["http://b.com/f"], "header": ["X-A: 1"]}` + "\nhttp://a.com/g\n  out=g.bin\n",
+			entries: []batchEntry{
+				{URL: "http://a.com/f", Out: "f.bin", Mirrors: []string{"http://b.com/f"}, Header: []string{"X-A: 1"}},
+				{URL: "http://a.com/g", Out: "g.bin"},
+			},
+		},
+		{
+			name:  "optionWithoutURL",
+			input: "# comment\n  out=f.bin\n",
+			err:   "Batch line 2: option without URL",
+		},
+		{
+			name:    "unknownOption",
+			input:   "http://a.com/1\nhttp://a.com/2\n  size=10\n",
+			entries: []batchEntry{{URL: "http://a.com/1"}},
+			err:     "Batch line 3: Unknown option size",
+		},
+		{
+			name:  "invalidOption",
+			input: "http://a.com/1\n\n  out\n",
+			err:   "Batch line 3: Invalid option out",
+		},
+		{
+			name:  "invalidJSON",
+			input: "http://a.com/1\n" + `{"url": "http://a.com/f", "size": 1}` + "\n",
+			err:   "Batch line 2: Invalid JSON entry",
+		},
+		{
+			name:  "jsonWithoutURL",
+			input: `{"out": "f.bin"}` + "\n",
+			err:   "Batch line 1: JSON entry without url",
+		},
+		{
+			name:  "invalidChecksum",
+			input: "http://a.com/f\n  checksum=sha-256=abc\n",
+			err:   "http://a.com/f: Invalid sha-256 digest",
+		},
+		{
+			name:  "unsupportedChecksum",
+			input: "http://a.com/f\n  checksum=crc32=00000000\n",
+			err:   "Unsupported checksum type crc32",
+		},
+		{
+			name:  "invalidHeader",
+			input: "http://a.com/f\n  header=X-A\n",
+			err:   "http://a.com/f: ",
+		},
+	}
+
+	for _, test := range tests {
+
+		var (
+			entries []batchEntry
+			err     error
+			b       = newBatchReader(bufio.NewScanner(strings.NewReader(test.input)))
+		)
+
+		for {
+
+			var e *batchEntry
+
+			if e, err = b.Next(); err != nil {
+				break
+			}
+
+			entries = append(entries, *e)
+		}
+
+		if test.err == "" && err != io.EOF {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+
+		if test.err != "" && (err == nil || err == io.EOF || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: expecting error %q, got: %v", test.name, test.err, err)
+		}
+
+		if !reflect.DeepEqual(entries, test.entries) {
+			t.Errorf("%s: expecting entries %+v, got: %+v", test.name, test.entries, entries)
+		}
+	}
+}
@@ -0,0 +1,603 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/melbahja/got"
+	"github.com/urfave/cli/v2"
+)
+
+// envPrefix is the prefix of the flags environment variables, e.g. GOT_MAX_REDIRECTS.
+const envPrefix = "GOT_"
+
+// hostOptions are the options of the host sections.
+var hostOptions = map[string]bool{
+	"header":      true,
+	"concurrency": true,
+	"size":        true,
+	"user":        true,
+	"bearer":      true,
+	"netrc":       true,
+	"proxy":       true,
+}
+
+// config is the config file, the option values are the flag values.
+// The flags are set by the command line, then the environment, then the host
+// section of the URL, then the global section.
+type config struct {
+
+	// global options are the keys before the first section.
+	global map[string][]string
+
+	// hosts are the [host."name"] sections, name is a host or host:port.
+	hosts map[string]map[string][]string
+
+	// explicit flags are set by the command line or the environment.
+	explicit map[string]bool
+
+	// explicitHeaders are the headers of the command line and the environment.
+	explicitHeaders http.Header
+
+	// profiles are the parsed host sections.
+	profiles map[string]*hostProfile
+}
+
+// hostProfile holds the options of a host section, nil options are not set.
+type hostProfile struct {
+	header http.Header
+
+	concurrency *uint
+
+	size *uint64
+
+	auth *got.Auth
+
+	client *http.Client
+}
+
+// loadConfig reads the --config file or the default config file, and sets the
+// flags from the environment variables and the global section.
+func loadConfig(c *cli.Context) (*config, error) {
+
+	cfg := &config{
+		global:   make(map[string][]string),
+		hosts:    make(map[string]map[string][]string),
+		explicit: make(map[string]bool),
+		profiles: make(map[string]*hostProfile),
+	}
+
+	names := flagNames(c)
+
+	for name, flag := range names {
+
+		if flag != name || name == "config" {
+			continue
+		}
+
+		if value, ok := os.LookupEnv(envName(name)); ok && !c.IsSet(name) {
+			if err := c.Set(name, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", envName(name), err)
+			}
+		}
+
+		if c.IsSet(name) {
+			cfg.explicit[name] = true
+		}
+	}
+
+	var err error
+
+	if cfg.explicitHeaders, err = parseHeaders(*c.Generic("header").(*headerFlag)); err != nil {
+		return nil, err
+	}
+
+	path := c.String("config")
+
+	if path == "" {
+		path = os.Getenv(envName("config"))
+	}
+
+	if path == "" {
+
+		if path, err = defaultConfigPath(); err != nil {
+			return cfg, nil
+		}
+
+		if _, err = os.Stat(path); os.IsNotExist(err) {
+			return cfg, nil
+		}
+	}
+
+	if err = cfg.parseFile(path, names); err != nil {
+		return nil, err
+	}
+
+	for name, values := range cfg.global {
+
+		// The headers are merged, the command line and environment keys are kept.
+		if name == "header" {
+			values = cfg.headers(values)
+		} else if cfg.explicit[name] {
+			continue
+		}
+
+		for _, value := range values {
+			if err = c.Set(name, value); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", path, name, err)
+			}
+		}
+	}
+
+	return cfg, cfg.prepareHosts()
+}
+
+// headers returns the header values of the keys not set by the command line or the environment.
+func (cfg *config) headers(values []string) (headers []string) {
+
+	for _, value := range values {
+
+		key, _, _ := strings.Cut(value, ":")
+		key, _, _ = strings.Cut(key, ";")
+
+		if _, ok := cfg.explicitHeaders[http.CanonicalHeaderKey(strings.TrimSpace(key))]; !ok {
+			headers = append(headers, value)
+		}
+	}
+
+	return headers
+}
+
+// flagNames returns the flag names and aliases with their flag name.
+func flagNames(c *cli.Context) map[string]string {
+
+	names := make(map[string]string)
+
+	for _, f := range c.App.Flags {
+
+		flagNames := f.Names()
+
+		for _, name := range flagNames {
+			names[name] = flagNames[0]
+		}
+	}
+
+	delete(names, "help")
+	delete(names, "version")
+
+	return names
+}
+
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/got/config.toml, or the user config dir of the OS.
+func defaultConfigPath() (string, error) {
+
+	dir := os.Getenv("XDG_CONFIG_HOME")
+
+	if dir == "" {
+
+		var err error
+
+		if dir, err = os.UserConfigDir(); err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(dir, "got", "config.toml"), nil
+}
+
+// parseFile parses the TOML subset of the config file: comments, key = value lines with
+// string, integer, boolean and array values, and [host."name"] sections.
+func (cfg *config) parseFile(path string, names map[string]string) error {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var (
+		scanner = bufio.NewScanner(file)
+		section = cfg.global
+		host    string
+		line    int
+	)
+
+	for scanner.Scan() {
+
+		line++
+
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+
+		// Arrays can span multiple lines.
+		for openArray(text) && scanner.Scan() {
+			line++
+			text += " " + strings.TrimSpace(stripComment(scanner.Text()))
+		}
+
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") {
+
+			if host, err = parseSection(text); err != nil {
+				return fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+
+			if cfg.hosts[host] == nil {
+				cfg.hosts[host] = make(map[string][]string)
+			}
+
+			section = cfg.hosts[host]
+			continue
+		}
+
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: Invalid line %s, expecting key = value", path, line, text)
+		}
+
+		name, err := parseKey(strings.TrimSpace(key))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+
+		flag, ok := names[name]
+
+		switch {
+		case !ok || flag == "config":
+			return fmt.Errorf("%s:%d: Unknown option %s", path, line, name)
+		case host != "" && !hostOptions[flag]:
+			return fmt.Errorf("%s:%d: Option %s is not supported in host sections", path, line, name)
+		}
+
+		values, err := parseValue(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", path, line, name, err)
+		}
+
+		section[flag] = append(section[flag], values...)
+	}
+
+	return scanner.Err()
+}
+
+// prepareHosts parses the host sections options.
+func (cfg *config) prepareHosts() (err error) {
+
+	for host, options := range cfg.hosts {
+
+		p := &hostProfile{}
+
+		if p.header, err = parseHeaders(options["header"]); err != nil {
+			return fmt.Errorf("[host.%q]: %w", host, err)
+		}
+
+		if v := last(options["concurrency"]); v != "" {
+
+			n, err := strconv.ParseUint(v, 10, 0)
+			if err != nil {
+				return fmt.Errorf("[host.%q]: Invalid concurrency %s", host, v)
+			}
+
+			concurrency := uint(n)
+			p.concurrency = &concurrency
+		}
+
+		if v := last(options["size"]); v != "" {
+
+			size, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("[host.%q]: Invalid size %s", host, v)
+			}
+
+			p.size = &size
+		}
+
+		if p.auth, err = hostAuth(options); err != nil {
+			return fmt.Errorf("[host.%q]: %w", host, err)
+		}
+
+		if v := last(options["proxy"]); v != "" {
+			if p.client, err = proxyClient(v); err != nil {
+				return fmt.Errorf("[host.%q]: %w", host, err)
+			}
+		}
+
+		cfg.profiles[host] = p
+	}
+
+	return nil
+}
+
+// hostAuth returns the credentials of a host section, the password can't be prompted.
+func hostAuth(options map[string][]string) (*got.Auth, error) {
+
+	user, bearer, netrc := last(options["user"]), last(options["bearer"]), last(options["netrc"]) == "true"
+
+	if user == "" && bearer == "" && !netrc {
+		return nil, nil
+	}
+
+	auth := &got.Auth{Token: bearer, Netrc: netrc}
+
+	if user != "" {
+
+		var ok bool
+
+		if auth.Username, auth.Password, ok = strings.Cut(user, ":"); !ok {
+			return nil, fmt.Errorf("Invalid user %s, expecting user:password", auth.Username)
+		}
+	}
+
+	return auth, nil
+}
+
+// setHostOptions sets the download options of its URL host section,
+// the command line and environment options are kept.
+func (cfg *config) setHostOptions(d *got.Download) {
+
+	u, err := url.Parse(d.URL)
+	if err != nil {
+		return
+	}
+
+	p, ok := cfg.profiles[strings.ToLower(u.Host)]
+	if !ok {
+		if p, ok = cfg.profiles[strings.ToLower(u.Hostname())]; !ok {
+			return
+		}
+	}
+
+	if len(p.header) > 0 {
+
+		header := d.RequestHeader.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+
+		for key, values := range p.header {
+			if _, ok := cfg.explicitHeaders[key]; !ok {
+				header[key] = values
+			}
+		}
+
+		d.RequestHeader = header
+	}
+
+	if p.concurrency != nil && !cfg.explicit["concurrency"] {
+		d.Concurrency = *p.concurrency
+	}
+
+	if p.size != nil && !cfg.explicit["size"] {
+		d.ChunkSize = *p.size
+	}
+
+	if p.auth != nil && !cfg.explicit["user"] && !cfg.explicit["bearer"] && !cfg.explicit["netrc"] {
+		d.Auth = p.auth
+	}
+
+	if p.client != nil && !cfg.explicit["proxy"] {
+		d.Client = p.client
+	}
+}
+
+// proxyClient returns a client of the default transport with the proxy URL.
+func proxyClient(proxy string) (*http.Client, error) {
+
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("Invalid proxy URL %s", proxy)
+	}
+
+	t := got.DefaultClient.Transport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyURL(u)
+
+	return &http.Client{Transport: t}, nil
+}
+
+// last returns the last value of an option, the options are set in order.
+func last(values []string) string {
+
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[len(values)-1]
+}
+
+// parseSection returns the host of a [host."name"] section.
+func parseSection(text string) (string, error) {
+
+	if !strings.HasSuffix(text, "]") {
+		return "", fmt.Errorf("Invalid section %s", text)
+	}
+
+	name := strings.TrimSpace(text[1 : len(text)-1])
+
+	if !strings.HasPrefix(name, "host.") {
+		return "", fmt.Errorf("Unknown section %s, expecting [host.\"name\"]", text)
+	}
+
+	host, err := parseKey(strings.TrimSpace(name[5:]))
+	if err != nil || host == "" {
+		return "", fmt.Errorf("Invalid section %s", text)
+	}
+
+	return strings.ToLower(host), nil
+}
+
+// parseKey returns the bare or quoted key.
+func parseKey(key string) (string, error) {
+
+	if strings.HasPrefix(key, `"`) || strings.HasPrefix(key, "'") {
+		return parseString(key)
+	}
+
+	for _, r := range key {
+		if !(r == '-' || r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return "", fmt.Errorf("Invalid key %s", key)
+		}
+	}
+
+	return key, nil
+}
+
+// parseValue returns the flag values of a string, integer, boolean or array value.
+func parseValue(value string) ([]string, error) {
+
+	if strings.HasPrefix(value, "[") {
+
+		if !strings.HasSuffix(value, "]") {
+			return nil, fmt.Errorf("Unterminated array %s", value)
+		}
+
+		var values []string
+
+		for _, item := range splitArray(value[1 : len(value)-1]) {
+
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+
+			v, err := parseValue(item)
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, v...)
+		}
+
+		return values, nil
+	}
+
+	switch {
+	case strings.HasPrefix(value, `"`), strings.HasPrefix(value, "'"):
+		s, err := parseString(value)
+		return []string{s}, err
+	case value == "true", value == "false":
+		return []string{value}, nil
+	}
+
+	n, err := strconv.ParseInt(strings.ReplaceAll(value, "_", ""), 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid value %s", value)
+	}
+
+	return []string{strconv.FormatInt(n, 10)}, nil
+}
+
+// parseString returns a "basic" or 'literal' string.
+func parseString(value string) (string, error) {
+
+	if len(value) < 2 || value[0] != value[len(value)-1] {
+		return "", fmt.Errorf("Invalid string %s", value)
+	}
+
+	if value[0] == '\'' {
+		return value[1 : len(value)-1], nil
+	}
+
+	s, err := strconv.Unquote(value)
+	if err != nil {
+		return "", fmt.Errorf("Invalid string %s", value)
+	}
+
+	return s, nil
+}
+
+// openArray reports whether the key = value line has an array value not closed on the line.
+func openArray(text string) bool {
+
+	_, value, ok := strings.Cut(text, "=")
+	if value = strings.TrimSpace(value); !ok || !strings.HasPrefix(value, "[") {
+		return false
+	}
+
+	var (
+		quote byte
+		depth int
+	)
+
+	for i := 0; i < len(value); i++ {
+
+		switch ch := value[i]; {
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '[':
+			depth++
+		case ch == ']':
+			depth--
+		}
+	}
+
+	return depth > 0
+}
+
+// splitArray splits the array items on the commas outside strings.
+func splitArray(items string) (values []string) {
+
+	var (
+		quote byte
+		start int
+	)
+
+	for i := 0; i < len(items); i++ {
+
+		switch ch := items[i]; {
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == ',':
+			values = append(values, items[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(values, items[start:])
+}
+
+// stripComment removes the # comment outside strings.
+func stripComment(line string) string {
+
+	var quote byte
+
+	for i := 0; i < len(line); i++ {
+
+		switch ch := line[i]; {
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '#':
+			return line[:i]
+		}
+	}
+
+	return line
+}
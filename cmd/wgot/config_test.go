@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigParse(t *testing.T) {
+
+	names := map[string]string{
+		"config":      "config",
+		"header":      "header",
+		"H":           "header",
+		"concurrency": "concurrency",
+		"c":           "concurrency",
+		"size":        "size",
+		"bearer":      "bearer",
+		"agent":       "agent",
+		"keep-going":  "keep-going",
+	}
+
+	type options map[string][]string
+
+	tests := []struct {
+		name   string
+		file   string
+		global options
+		hosts  map[string]options
+		err    string
+	}{
+		{
+			name:   "values",
+			file:   "agent = \"got \\\"1\\\"\"\nbearer = 'lit\\eral'\nconcurrency = 1_0\nsize = 0x10\nkeep-going = true\n",
+			global: options{"agent": {`got "1"`}, "bearer": {`lit\eral`}, "concurrency": {"10"}, "size": {"16"}, "keep-going": {"true"}},
+		},
+		{
+			name:   "aliases",
+			file:   "c = 2\n\"H\" = \"X-A: 1\"\n",
+			global: options{"concurrency": {"2"}, "header": {"X-A: 1"}},
+		},
+		{
+			name:   "arrays",
+			file:   "header = [\"X-A: 1\", 'X-B: [2]',]\nheader = \"X-C: 3\"\nheader = []\n",
+			global: options{"header": {"X-A: 1", "X-B: [2]", "X-C: 3"}},
+		},
+		{
+			name:   "multiLineArray",
+			file:   "header = [\n  \"X-A: 1\", # first\n  \"X-B: #2\",\n]\nagent = \"got\"\n",
+			global: options{"header": {"X-A: 1", "X-B: #2"}, "agent": {"got"}},
+		},
+		{
+			name:   "unbalancedString",
+			file:   "header = \"X-A: [a\"\nagent = \"got\"\nheader = ['X-B: [b']\n",
+			global: options{"header": {"X-A: [a", "X-B: [b"}, "agent": {"got"}},
+		},
+		{
+			name:   "comments",
+			file:   "# comment\n\n  agent = \"a # b\" # comment\nbearer = 'c#d'#\n",
+			global: options{"agent": {"a # b"}, "bearer": {"c#d"}},
+		},
+		{
+			name:   "hosts",
+			file:   "size = 1\n[host.\"Example.com\"]\nsize = 2\nheader = \"X-A: 1\"\n[host.'example.com:8080']\nbearer = \"t\"\n[host.\"example.com\"]\nconcurrency = 3\n",
+			global: options{"size": {"1"}},
+			hosts: map[string]options{
+				"example.com":      {"size": {"2"}, "header": {"X-A: 1"}, "concurrency": {"3"}},
+				"example.com:8080": {"bearer": {"t"}},
+			},
+		},
+		{name: "unknownOption", file: "colour = true\n", err: ":1: Unknown option colour"},
+		{name: "configOption", file: "config = \"x\"\n", err: "Unknown option config"},
+		{name: "hostOption", file: "\n[host.\"a.com\"]\nagent = \"x\"\n", err: ":3: Option agent is not supported in host sections"},
+		{name: "invalidLine", file: "agent\n", err: ":1: Invalid line agent"},
+		{name: "invalidKey", file: "a b = 1\n", err: "Invalid key a b"},
+		{name: "invalidValue", file: "size = big\n", err: "size: Invalid value big"},
+		{name: "invalidString", file: "agent = \"got\n", err: "Invalid string"},
+		{name: "unterminatedArray", file: "header = [\"X-A: 1\",\n", err: "Unterminated array"},
+		{name: "unknownSection", file: "[proxy]\n", err: "Unknown section [proxy]"},
+		{name: "invalidSection", file: "[host.\"\"]\n", err: "Invalid section"},
+	}
+
+	for _, test := range tests {
+
+		path := filepath.Join(t.TempDir(), "config.toml")
+
+		if err := os.WriteFile(path, []byte(test.file), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := &config{global: make(map[string][]string), hosts: make(map[string]map[string][]string)}
+
+		err := cfg.parseFile(path, names)
+
+		if test.err != "" {
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expecting error %q, got: %v", test.name, test.err, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		if test.global == nil {
+			test.global = options{}
+		}
+
+		if !reflect.DeepEqual(options(cfg.global), test.global) {
+			t.Errorf("%s: expecting global %v, got: %v", test.name, test.global, cfg.global)
+		}
+
+		hosts := make(map[string]options)
+		for host, o := range cfg.hosts {
+			hosts[host] = o
+		}
+
+		if test.hosts == nil {
+			test.hosts = map[string]options{}
+		}
+
+		if !reflect.DeepEqual(hosts, test.hosts) {
+			t.Errorf("%s: expecting hosts %v, got: %v", test.name, test.hosts, hosts)
+		}
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+
+	var header http.Header
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+
+	file := `
+agent = "global"
+bearer = "global"
+header = ["X-A: global", "X-B: global", "X-C: global"]
+
+[host."` + u.Host + `"]
+bearer = "host"
+header = ["X-A: host", "X-B: host"]
+`
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+
+	if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+
+		// expect are the expected request headers.
+		expect map[string]string
+	}{
+		{
+			name:   "config",
+			expect: map[string]string{"User-Agent": "global", "Authorization": "Bearer host", "X-A": "host", "X-B": "host", "X-C": "global"},
+		},
+		{
+			name:   "env",
+			env:    map[string]string{"GOT_AGENT": "env", "GOT_BEARER": "env", "GOT_HEADER": "X-A: env"},
+			expect: map[string]string{"User-Agent": "env", "Authorization": "Bearer env", "X-A": "env", "X-B": "host", "X-C": "global"},
+		},
+		{
+			name:   "cli",
+			args:   []string{"--agent", "cli", "--bearer", "cli", "-H", "X-B: cli"},
+			env:    map[string]string{"GOT_AGENT": "env", "GOT_BEARER": "env", "GOT_HEADER": "X-A: env"},
+			expect: map[string]string{"User-Agent": "cli", "Authorization": "Bearer cli", "X-A": "host", "X-B": "cli", "X-C": "global"},
+		},
+	}
+
+	for _, test := range tests {
+
+		t.Run(test.name, func(t *testing.T) {
+
+			t.Setenv("GOT_CONFIG", path)
+
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+
+			args := append(append([]string{"got", "-d", t.TempDir()}, test.args...), server.URL+"/file.txt")
+
+			if err := newApp(context.Background()).Run(args); err != nil {
+				t.Fatal(err)
+			}
+
+			for key, value := range test.expect {
+				if header.Get(key) != value {
+					t.Errorf("Expecting %s: %s, got: %q", key, value, header.Get(key))
+				}
+			}
+		})
+	}
+}
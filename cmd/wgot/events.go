@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/melbahja/got"
+	"github.com/urfave/cli/v2"
+)
+
+// progressInterval is the minimum interval between the progress events of a download.
+const progressInterval = time.Second
+
+// event is a JSON output event.
+type event struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+
+	URL  string `json:"url,omitempty"`
+	Path string `json:"path,omitempty"`
+
+	// Size is the total size of start and progress events, and the downloaded size of complete events.
+	Size uint64 `json:"size,omitempty"`
+
+	Rangeable *bool `json:"rangeable,omitempty"`
+
+	Downloaded uint64 `json:"downloaded,omitempty"`
+
+	// Speed is the current speed of progress events, and the average speed of complete events, in bytes per second.
+	Speed uint64 `json:"speed,omitempty"`
+
+	// Duration is the download duration in seconds.
+	Duration float64 `json:"duration,omitempty"`
+
+	Checksum string `json:"checksum,omitempty"`
+
+	Mirror string `json:"mirror,omitempty"`
+
+	Error string `json:"error,omitempty"`
+
+	// Message is the log line of log events.
+	Message string `json:"message,omitempty"`
+
+	// The download counts of the summary event.
+	Succeeded int `json:"succeeded,omitempty"`
+	Failed    int `json:"failed,omitempty"`
+	Skipped   int `json:"skipped,omitempty"`
+}
+
+// jsonReporter writes newline delimited JSON events.
+type jsonReporter struct {
+	mu sync.Mutex
+
+	enc *json.Encoder
+
+	// file is the events file, closed by Finish.
+	file io.Closer
+
+	// started downloads with their last progress event time.
+	started map[*got.Download]time.Time
+
+	// finished downloads are ignored by late progress calls.
+	finished map[*got.Download]bool
+
+	// summary counts the finished downloads and their size.
+	summary event
+
+	// begin is the run start time.
+	begin time.Time
+}
+
+func newJSONReporter(out io.Writer, file io.Closer) *jsonReporter {
+	return &jsonReporter{
+		enc:      json.NewEncoder(out),
+		file:     file,
+		started:  make(map[*got.Download]time.Time),
+		finished: make(map[*got.Download]bool),
+		summary:  event{Event: "summary"},
+		begin:    time.Now(),
+	}
+}
+
+func (j *jsonReporter) Add(n int) {}
+
+// Update writes the start event of a new download, then its progress events.
+func (j *jsonReporter) Update(d *got.Download) {
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.finished[d] {
+		return
+	}
+
+	last, ok := j.started[d]
+
+	if !ok {
+		j.start(d)
+		return
+	}
+
+	if time.Since(last) < progressInterval {
+		return
+	}
+
+	j.started[d] = time.Now()
+	j.write(event{
+		Event:      "progress",
+		URL:        d.URL,
+		Path:       d.Path(),
+		Size:       d.TotalSize(),
+		Downloaded: d.Size(),
+		Speed:      d.Speed(),
+	})
+}
+
+func (j *jsonReporter) Done(d *got.Download, name string, err error) {
+
+	var checksum string
+
+	// Hash the file before locking, it would block the events of the other downloads.
+	if err == nil {
+		if sum, err := fileChecksum(d.Path(), "sha-256"); err == nil {
+			checksum = "sha-256=" + sum
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, started := j.started[d]
+
+	j.summary.Size += d.Size()
+
+	if err != nil {
+
+		j.summary.Failed++
+
+		e := event{Event: "error", URL: d.URL, Error: err.Error()}
+
+		// The path isn't known before the download starts.
+		if started {
+			e.Path = d.Path()
+		}
+
+		j.finish(d)
+		j.write(e)
+		return
+	}
+
+	if !started {
+		j.start(d)
+	}
+
+	j.finish(d)
+	j.summary.Succeeded++
+
+	e := event{
+		Event:    "complete",
+		URL:      d.URL,
+		Path:     d.Path(),
+		Size:     d.Size(),
+		Speed:    d.AvgSpeed(),
+		Duration: d.TotalCost().Seconds(),
+		Checksum: checksum,
+	}
+
+	j.write(e)
+}
+
+func (j *jsonReporter) Skip(d *got.Download, name string) {
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.finish(d)
+	j.summary.Skipped++
+	j.write(event{Event: "skip", URL: d.URL, Path: name})
+}
+
+func (j *jsonReporter) Retry(d *got.Download, URL string, err error, mirror string) {
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if d != nil {
+		j.finish(d)
+	}
+
+	j.write(event{Event: "retry", URL: URL, Mirror: mirror, Error: err.Error()})
+}
+
+// Finish writes the summary and the run error events, the error is not printed again.
+func (j *jsonReporter) Finish(err error) error {
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file != nil {
+		defer j.file.Close()
+	}
+
+	j.summary.Duration = time.Since(j.begin).Seconds()
+
+	if j.summary.Duration > 0 {
+		j.summary.Speed = uint64(float64(j.summary.Size) / j.summary.Duration)
+	}
+
+	j.write(j.summary)
+
+	if err == nil {
+		return nil
+	}
+
+	j.write(event{Event: "error", Error: err.Error()})
+
+	return cli.Exit("", 1)
+}
+
+// Write writes the log lines as log events.
+func (j *jsonReporter) Write(p []byte) (int, error) {
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		j.write(event{Event: "log", Message: line})
+	}
+
+	return len(p), nil
+}
+
+func (j *jsonReporter) start(d *got.Download) {
+
+	rangeable := d.IsRangeable()
+
+	j.started[d] = time.Now()
+	j.write(event{
+		Event:     "start",
+		URL:       d.URL,
+		Path:      d.Path(),
+		Size:      d.TotalSize(),
+		Rangeable: &rangeable,
+	})
+}
+
+func (j *jsonReporter) finish(d *got.Download) {
+	j.finished[d] = true
+	delete(j.started, d)
+}
+
+func (j *jsonReporter) write(e event) {
+
+	e.Time = time.Now()
+
+	// The events are best effort, a write error doesn't stop the downloads.
+	j.enc.Encode(e)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxGlobURLs is the maximum number of URLs of an expanded glob.
+const maxGlobURLs = 100000
+
+// globURL is an expanded glob URL with the values of its globs.
+type globURL struct {
+	URL string
+
+	values []string
+}
+
+// Output replaces the #N references of the output name with the Nth glob value.
+func (u globURL) Output(name string) string {
+
+	var b strings.Builder
+
+	for i := 0; i < len(name); i++ {
+
+		if name[i] == '#' {
+
+			j := i + 1
+			for j < len(name) && name[j] >= '0' && name[j] <= '9' {
+				j++
+			}
+
+			if n, err := strconv.Atoi(name[i+1 : j]); err == nil && n > 0 && n <= len(u.values) {
+				b.WriteString(u.values[n-1])
+				i = j - 1
+				continue
+			}
+		}
+
+		b.WriteByte(name[i])
+	}
+
+	return b.String()
+}
+
+// expandGlob expands the curl style globs of the URL: {a,b} sets, [1-10] and [001-100:5]
+// numeric ranges, and [a-z] alphabetic ranges. The leftmost glob changes slowest,
+// and \ escapes the glob characters. The brackets of an IPv6 host are not globs.
+func expandGlob(URL string) ([]globURL, error) {
+
+	var (
+		parts = []string{""}
+		globs [][]string
+		total = 1
+	)
+
+	for i := 0; i < len(URL); i++ {
+
+		switch ch := URL[i]; ch {
+
+		case '\\':
+			if i+1 < len(URL) && strings.IndexByte("{}[]\\", URL[i+1]) >= 0 {
+				i++
+			}
+			parts[len(parts)-1] += string(URL[i])
+
+		case '{', '[':
+
+			closing := byte('}')
+			if ch == '[' {
+				closing = ']'
+			}
+
+			end := strings.IndexByte(URL[i+1:], closing)
+			if end < 0 {
+				return nil, fmt.Errorf("Unmatched %c in URL glob %s", ch, URL)
+			}
+
+			body := URL[i+1 : i+1+end]
+
+			if ch == '[' && strings.HasSuffix(URL[:i], "://") {
+				parts[len(parts)-1] += URL[i : i+end+2]
+				i += end + 1
+				continue
+			}
+
+			var (
+				values []string
+				err    error
+			)
+
+			if ch == '{' {
+				values = strings.Split(body, ",")
+			} else if values, err = globRange(body); err != nil {
+				return nil, fmt.Errorf("Invalid URL glob [%s]: %w", body, err)
+			}
+
+			if total *= len(values); total > maxGlobURLs {
+				return nil, fmt.Errorf("URL glob %s expands to more than %d URLs", URL, maxGlobURLs)
+			}
+
+			globs = append(globs, values)
+			parts = append(parts, "")
+			i += end + 1
+
+		case '}', ']':
+			return nil, fmt.Errorf("Unmatched %c in URL glob %s", ch, URL)
+
+		default:
+			parts[len(parts)-1] += string(ch)
+		}
+	}
+
+	urls := make([]globURL, 0, total)
+	index := make([]int, len(globs))
+
+	for {
+
+		u := globURL{URL: parts[0], values: make([]string, len(globs))}
+
+		for i, values := range globs {
+			u.values[i] = values[index[i]]
+			u.URL += u.values[i] + parts[i+1]
+		}
+
+		urls = append(urls, u)
+
+		// Next combination, the rightmost glob changes fastest.
+		i := len(globs) - 1
+		for ; i >= 0; i-- {
+			if index[i]++; index[i] < len(globs[i]) {
+				break
+			}
+			index[i] = 0
+		}
+
+		if i < 0 {
+			return urls, nil
+		}
+	}
+}
+
+// globRange returns the values of a numeric or alphabetic range with an optional step,
+// the numbers are padded to the start width when it has leading zeros.
+func globRange(body string) ([]string, error) {
+
+	body, stepStr, hasStep := strings.Cut(body, ":")
+
+	step := 1
+
+	if hasStep {
+
+		var err error
+
+		if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+			return nil, errors.New("invalid step")
+		}
+	}
+
+	start, end, ok := strings.Cut(body, "-")
+	if !ok || start == "" || end == "" {
+		return nil, errors.New("expecting a start-end range")
+	}
+
+	var values []string
+
+	if len(start) == 1 && len(end) == 1 && isLetter(start[0]) && isLetter(end[0]) {
+
+		if (start[0] <= 'Z') != (end[0] <= 'Z') || start[0] > end[0] {
+			return nil, errors.New("invalid alphabetic range")
+		}
+
+		for ch := int(start[0]); ; ch += step {
+
+			values = append(values, string(rune(ch)))
+
+			if int(end[0])-ch < step {
+				break
+			}
+		}
+
+		return values, nil
+	}
+
+	from, err := strconv.ParseUint(start, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid range start")
+	}
+
+	to, err := strconv.ParseUint(end, 10, 64)
+	if err != nil || to < from {
+		return nil, errors.New("invalid range end")
+	}
+
+	if (to-from)/uint64(step) >= maxGlobURLs {
+		return nil, fmt.Errorf("more than %d values", maxGlobURLs)
+	}
+
+	width := 0
+	if len(start) > 1 && start[0] == '0' {
+		width = len(start)
+	}
+
+	// The loop stops before n += step can overflow near MaxUint64.
+	for n := from; ; n += uint64(step) {
+
+		values = append(values, fmt.Sprintf("%0*d", width, n))
+
+		if to-n < uint64(step) {
+			break
+		}
+	}
+
+	return values, nil
+}
+
+func isLetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
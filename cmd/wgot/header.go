@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// headerFlag collects repeated header flags without splitting the values on commas.
+type headerFlag []string
+
+func (h *headerFlag) Set(value string) error {
+	*h = append(*h, value)
+	return nil
+}
+
+func (h *headerFlag) String() string {
+	return strings.Join(*h, ", ")
+}
+
+// parseHeaders parses curl style headers, "Key: Value" adds a value,
+// "Key:" removes the header and "Key;" adds an empty value.
+func parseHeaders(values []string) (http.Header, error) {
+
+	header := make(http.Header)
+
+	for _, h := range values {
+
+		if key := strings.TrimSpace(strings.TrimSuffix(h, ";")); strings.HasSuffix(h, ";") && !strings.ContainsAny(key, ":") {
+			key = textproto.CanonicalMIMEHeaderKey(key)
+			header[key] = append(header[key], "")
+			continue
+		}
+
+		split := strings.SplitN(h, ":", 2)
+		if len(split) == 1 || strings.TrimSpace(split[0]) == "" {
+			return nil, errors.New("malformatted header " + h)
+		}
+
+		key, value := textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(split[0])), strings.TrimSpace(split[1])
+
+		if value == "" {
+			if _, ok := header[key]; !ok {
+				header[key] = nil
+			}
+			continue
+		}
+
+		header[key] = append(header[key], value)
+	}
+
+	return header, nil
+}
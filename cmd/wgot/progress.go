@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/melbahja/got"
+	"gitlab.com/poldi1405/go-ansi"
+	"gitlab.com/poldi1405/go-indicators/progress"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// nameWidth is the width of the file names in the progress lines.
+const nameWidth = 24
+
+// reporter shows the progress and results of the downloads.
+type reporter interface {
+
+	// Add counts scheduled downloads.
+	Add(n int)
+
+	// Update is the got ProgressFunc.
+	Update(d *got.Download)
+
+	// Done reports a finished download, name is its URL or path.
+	Done(d *got.Download, name string, err error)
+
+	// Skip reports an unchanged or existing file that is not downloaded.
+	Skip(d *got.Download, name string)
+
+	// Retry reports a failed URL that is retried with a mirror, d is nil for recursive downloads.
+	Retry(d *got.Download, URL string, err error, mirror string)
+
+	// Finish is called when all the downloads are done, it returns the run error.
+	Finish(err error) error
+
+	// Write writes the log lines without breaking the output.
+	io.Writer
+}
+
+// progressBoard shows one progress line per active download and an overall line
+// below the printed messages, the lines are redrawn in place on terminals.
+type progressBoard struct {
+	mu sync.Mutex
+
+	tty bool
+
+	// quiet board only prints the failures.
+	quiet bool
+
+	bar *progress.Progress
+
+	// active downloads in start order.
+	active []*got.Download
+
+	// finished downloads are not added again by late progress calls.
+	finished map[*got.Download]bool
+
+	// lines is the number of drawn board lines.
+	lines int
+
+	done, total int
+
+	// doneBytes is the size of the finished downloads.
+	doneBytes uint64
+
+	lastDraw time.Time
+
+	// The summary counts, failures are the failed names with their errors.
+	succeeded, skipped int
+	failures           []string
+
+	started time.Time
+}
+
+func newProgressBoard(quiet bool) *progressBoard {
+
+	bar := new(progress.Progress)
+	bar.SetStyle(progressStyle)
+
+	return &progressBoard{
+		tty:      terminal.IsTerminal(int(os.Stdout.Fd())) && !quiet,
+		quiet:    quiet,
+		bar:      bar,
+		finished: make(map[*got.Download]bool),
+		started:  time.Now(),
+	}
+}
+
+// Add counts a scheduled download in the overall total.
+func (b *progressBoard) Add(n int) {
+	b.mu.Lock()
+	b.total += n
+	b.mu.Unlock()
+}
+
+// Update is the got ProgressFunc, it adds the unknown downloads and redraws the board.
+func (b *progressBoard) Update(d *got.Download) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.finished[d] {
+		return
+	}
+
+	found := false
+	for _, a := range b.active {
+		if a == d {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		b.active = append(b.active, d)
+	}
+
+	// The downloads call Update concurrently, one redraw per interval is enough.
+	if time.Since(b.lastDraw) >= 100*time.Millisecond {
+		b.draw()
+	}
+}
+
+// Done removes the download from the board, counts it as done and prints its result above the board.
+func (b *progressBoard) Done(d *got.Download, name string, err error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(d)
+	b.done++
+	b.doneBytes += d.Size()
+
+	if err != nil {
+		b.failures = append(b.failures, fmt.Sprintf("%s: %v", name, err))
+		b.print("✘ %s: %v", name, err)
+		return
+	}
+
+	b.succeeded++
+
+	if !b.quiet {
+		b.print("✔ %s", name)
+	}
+}
+
+// Skip counts the unchanged or existing file as done.
+func (b *progressBoard) Skip(d *got.Download, name string) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(d)
+	b.done++
+	b.skipped++
+
+	reason := "unchanged"
+	if d.Skipped() {
+		reason = "exists"
+	}
+
+	if !b.quiet {
+		b.print("- %s (%s)", name, reason)
+	}
+}
+
+// Retry removes the failed download, its URL is retried with the next mirror.
+func (b *progressBoard) Retry(d *got.Download, URL string, err error, mirror string) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if d != nil {
+		b.remove(d)
+	}
+
+	b.print("✘ %s: %v, trying mirror %s", URL, err, mirror)
+}
+
+// Write writes the log lines to stderr above the board.
+func (b *progressBoard) Write(p []byte) (int, error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.clear()
+	defer b.draw()
+
+	return os.Stderr.Write(p)
+}
+
+func (b *progressBoard) remove(d *got.Download) {
+
+	b.finished[d] = true
+
+	for i, a := range b.active {
+		if a == d {
+			b.active = append(b.active[:i], b.active[i+1:]...)
+			return
+		}
+	}
+}
+
+// Finish clears the board and prints the summary of multiple downloads, it returns the run error.
+func (b *progressBoard) Finish(err error) error {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.clear()
+
+	if !b.quiet && b.done > 1 {
+		b.summary()
+	}
+
+	return err
+}
+
+func (b *progressBoard) summary() {
+
+	elapsed := time.Since(b.started)
+
+	var speed uint64
+	if elapsed > 0 {
+		speed = uint64(float64(b.doneBytes) / elapsed.Seconds())
+	}
+
+	fmt.Println()
+	fmt.Printf(" %-10s %d\n", "Succeeded", b.succeeded)
+	fmt.Printf(" %-10s %d\n", "Failed", len(b.failures))
+
+	for _, f := range b.failures {
+		fmt.Printf(" %-10s ✘ %s\n", "", f)
+	}
+
+	fmt.Printf(" %-10s %d\n", "Skipped", b.skipped)
+	fmt.Printf(" %-10s %s\n", "Total", humanize.Bytes(b.doneBytes))
+	fmt.Printf(" %-10s %s\n", "Elapsed", elapsed.Round(10*time.Millisecond))
+	fmt.Printf(" %-10s %s/s\n", "Speed", humanize.Bytes(speed))
+}
+
+func (b *progressBoard) print(format string, args ...interface{}) {
+
+	b.clear()
+
+	if format != "" {
+		fmt.Printf(format+"\n", args...)
+	}
+
+	b.draw()
+}
+
+// clear moves the cursor to the first board line and clears the board.
+func (b *progressBoard) clear() {
+
+	if b.lines > 0 {
+		fmt.Print("\r" + ansi.UpX(b.lines) + ansi.ClearEnd())
+		b.lines = 0
+	}
+}
+
+func (b *progressBoard) draw() {
+
+	if !b.tty {
+		return
+	}
+
+	b.lastDraw = time.Now()
+
+	var (
+		out   strings.Builder
+		width = getWidth()
+		size  = b.doneBytes
+		speed uint64
+	)
+
+	if b.lines > 0 {
+		out.WriteString("\r" + ansi.UpX(b.lines) + ansi.ClearEnd())
+	}
+
+	for _, d := range b.active {
+		out.WriteString(b.line(d, width) + "\n")
+		size += d.Size()
+		speed += d.Speed()
+	}
+
+	fmt.Fprintf(&out, " %d/%d files %s @ %s/s\n", b.done, b.total, humanize.Bytes(size), humanize.Bytes(speed))
+
+	b.lines = len(b.active) + 1
+
+	fmt.Print(out.String())
+}
+
+// line returns the progress line of a download.
+func (b *progressBoard) line(d *got.Download, width int) string {
+
+	perc, err := progress.GetPercentage(float64(d.Size()), float64(d.TotalSize()))
+	if err != nil {
+		perc = 100
+	}
+
+	name := filepath.Base(d.Path())
+	if runes := []rune(name); len(runes) > nameWidth {
+		name = string(runes[:nameWidth-1]) + "…"
+	}
+
+	// 55 is just an estimation of the text showed with the progress,
+	// the bar is hidden when there isn't enough room.
+	var bar string
+
+	if b.bar.Width = width - 55 - nameWidth; b.bar.Width >= 10 {
+		bar = r + color(b.bar.GetBar(perc, 100)) + l
+	}
+
+	return fmt.Sprintf(
+		" %-*s %6.2f%% %s %s/%s @ %s/s",
+		nameWidth,
+		name,
+		perc,
+		bar,
+		humanize.Bytes(d.Size()),
+		humanize.Bytes(d.TotalSize()),
+		humanize.Bytes(d.Speed()),
+	)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/melbahja/got"
+	"github.com/urfave/cli/v2"
+)
+
+// queue collects the downloads of the entries, they are run by Got.DownloadBatch.
+type queue struct {
+	downloads []*got.Download
+
+	// entries are the entries of the queued downloads.
+	entries map[*got.Download]*batchEntry
+}
+
+func newQueue() *queue {
+	return &queue{entries: make(map[*got.Download]*batchEntry)}
+}
+
+// read queues the entries of a batch input.
+func (q *queue) read(c *cli.Context, scanner *bufio.Scanner) {
+
+	batch := newBatchReader(scanner)
+
+	for {
+
+		e, err := batch.Next()
+		if err == io.EOF {
+			return
+		}
+
+		// The batch errors have the line number or the entry URL.
+		if err != nil {
+			q.fail("batch", err)
+			continue
+		}
+
+		q.add(c, e)
+	}
+}
+
+// add expands the entry URL globs, unless --globoff is set or it's a data URL,
+// and queues the URLs downloads.
+func (q *queue) add(c *cli.Context, e *batchEntry) {
+
+	urls := []globURL{{URL: e.URL}}
+
+	if !c.Bool("globoff") && !strings.HasPrefix(e.URL, "data:") {
+
+		var err error
+
+		if urls, err = expandGlob(e.URL); err != nil {
+			q.fail(e.URL, err)
+			return
+		}
+	}
+
+	// The files of recursive downloads are counted when they are done.
+	if !multiFile(c) {
+		Progress.Add(len(urls))
+	}
+
+	for _, u := range urls {
+
+		entry := *e
+		entry.URL, entry.Out = u.URL, u.Output(e.Out)
+
+		q.push(&entry)
+	}
+}
+
+// fail queues an entry that failed before its downloads, the error is
+// reported when it runs, so --keep-going and --max-failures count it.
+func (q *queue) fail(name string, err error) {
+
+	Progress.Add(1)
+	q.push(&batchEntry{URL: name, err: err})
+}
+
+func (q *queue) push(e *batchEntry) {
+
+	d := &got.Download{URL: e.URL, Dest: e.Out}
+
+	q.downloads = append(q.downloads, d)
+	q.entries[d] = e
+}
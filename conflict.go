@@ -0,0 +1,151 @@
+package got
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConflictPolicy is the action taken when the download file already exists.
+type ConflictPolicy int
+
+const (
+
+	// ConflictOverwrite truncates the existing file, the default.
+	ConflictOverwrite ConflictPolicy = iota
+
+	// ConflictSkip keeps the existing file and skips the download.
+	ConflictSkip
+
+	// ConflictSkipSameSize skips the download when the existing file has the
+	// remote file size, otherwise the file is overwritten. The DASH track sizes
+	// are unknown, their existing files are skipped like ConflictSkip.
+	ConflictSkipSameSize
+
+	// ConflictRename saves the file as "name (1).ext", or the next free number.
+	ConflictRename
+
+	// ConflictFail fails the download with ErrFileExists.
+	ConflictFail
+)
+
+// maxRenames is the maximum number tried by ConflictRename.
+const maxRenames = 10000
+
+// ErrFileExists is returned by ConflictFail downloads when the file exists.
+var ErrFileExists = errors.New("File already exists")
+
+// conflictPolicies are the policy names.
+var conflictPolicies = []string{"overwrite", "skip", "skip-same-size", "rename", "fail"}
+
+func (p ConflictPolicy) String() string {
+
+	if p < 0 || int(p) >= len(conflictPolicies) {
+		return fmt.Sprintf("ConflictPolicy(%d)", p)
+	}
+
+	return conflictPolicies[p]
+}
+
+// ParseConflictPolicy returns the policy of a name: overwrite, skip, skip-same-size, rename or fail.
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+
+	for i, n := range conflictPolicies {
+		if n == name {
+			return ConflictPolicy(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown conflict policy %s, expecting one of: %s", name, strings.Join(conflictPolicies, ", "))
+}
+
+// Skipped reports whether the download was skipped because its file exists.
+func (d *Download) Skipped() bool {
+	return d.skipped
+}
+
+// createFile creates the file of path using the ConflictPolicy, size is the remote file size, 0 if unknown.
+// It returns the created file and its path, or a nil file when the download is skipped.
+// The file is created exclusively, unless it's overwritten.
+func (d *Download) createFile(path string, size uint64) (*os.File, string, error) {
+
+	policy := d.ConflictPolicy
+
+	if policy == ConflictSkipSameSize {
+
+		stat, err := os.Stat(path)
+
+		switch {
+		case err == nil && size > 0 && uint64(stat.Size()) == size:
+			policy = ConflictSkip
+		default:
+			policy = ConflictOverwrite
+		}
+	}
+
+	return d.createPolicyFile(path, policy)
+}
+
+// createPolicyFile creates the file of path using the policy, ConflictSkipSameSize is not handled.
+func (d *Download) createPolicyFile(path string, policy ConflictPolicy) (*os.File, string, error) {
+
+	if policy == ConflictOverwrite {
+		file, err := os.Create(path)
+		return file, path, err
+	}
+
+	file, err := createExclusive(path)
+
+	if !errors.Is(err, os.ErrExist) {
+		return file, path, err
+	}
+
+	switch policy {
+	case ConflictSkip:
+		d.log(LogInfo, "file exists, skipping", "url", d.URL, "path", path)
+		return nil, path, nil
+	case ConflictRename:
+	default:
+		return nil, path, fmt.Errorf("%s: %w", path, ErrFileExists)
+	}
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+
+	for i := 1; i <= maxRenames; i++ {
+
+		name := fmt.Sprintf("%s (%d)%s", base, i, ext)
+
+		if file, err = createExclusive(name); !errors.Is(err, os.ErrExist) {
+
+			if err == nil {
+				d.log(LogInfo, "file exists, renamed", "url", d.URL, "path", name)
+			}
+
+			return file, name, err
+		}
+	}
+
+	return nil, path, fmt.Errorf("%s: %w, no free name", path, ErrFileExists)
+}
+
+// createDownloadFile creates the download file, it updates the path when it's renamed,
+// and marks the download skipped when the file is nil.
+func (d *Download) createDownloadFile(size uint64) (*os.File, error) {
+
+	file, path, err := d.createFile(d.Path(), size)
+	if err != nil {
+		return nil, err
+	}
+
+	d.setPath(path)
+	d.skipped = file == nil
+
+	return file, nil
+}
+
+func createExclusive(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+}
@@ -0,0 +1,291 @@
+package got_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/melbahja/got"
+)
+
+func TestConflictPolicy(t *testing.T) {
+
+	t.Run("overwrite", conflictOverwriteTest)
+	t.Run("skip", conflictSkipTest)
+	t.Run("skipSameSize", conflictSkipSameSizeTest)
+	t.Run("rename", conflictRenameTest)
+	t.Run("renameProgress", conflictRenameProgressTest)
+	t.Run("fail", conflictFailTest)
+	t.Run("exclusive", conflictExclusiveTest)
+	t.Run("parse", conflictParseTest)
+}
+
+// conflictDownload downloads the URL path into dir/file with the policy.
+func conflictDownload(dir, path string, policy got.ConflictPolicy) (*got.Download, error) {
+
+	d := got.NewDownload(context.Background(), httpt.URL+path, filepath.Join(dir, "file"))
+	d.ConflictPolicy = policy
+
+	if err := d.Init(); err != nil {
+		return d, err
+	}
+
+	return d, d.Start()
+}
+
+func writeExisting(t *testing.T, content string) string {
+
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "file"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func assertContent(t *testing.T, path, expected string) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != expected {
+		t.Errorf("Expecting %s content %q, got: %q", path, expected, data)
+	}
+}
+
+func conflictOverwriteTest(t *testing.T) {
+
+	for _, path := range []string{"/ok_file", "/found_and_head_not_allowed"} {
+
+		dir := writeExisting(t, "existing file")
+
+		d, err := conflictDownload(dir, path, got.ConflictOverwrite)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.Skipped() {
+			t.Error("Expecting the download to not be skipped")
+		}
+
+		if info, _ := os.Stat(d.Path()); info.Size() == int64(len("existing file")) {
+			t.Errorf("Expecting %s to be overwritten", path)
+		}
+	}
+}
+
+func conflictSkipTest(t *testing.T) {
+
+	for _, path := range []string{"/ok_file", "/found_and_head_not_allowed"} {
+
+		dir := writeExisting(t, "existing file")
+
+		d, err := conflictDownload(dir, path, got.ConflictSkip)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !d.Skipped() {
+			t.Errorf("Expecting %s download to be skipped", path)
+		}
+
+		assertContent(t, filepath.Join(dir, "file"), "existing file")
+	}
+
+	// The missing file is downloaded.
+	d, err := conflictDownload(t.TempDir(), "/found_and_head_not_allowed", got.ConflictSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Skipped() {
+		t.Error("Expecting the missing file to be downloaded")
+	}
+
+	assertContent(t, d.Path(), "helloworld")
+}
+
+func conflictSkipSameSizeTest(t *testing.T) {
+
+	data, err := os.ReadFile("go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := writeExisting(t, string(data))
+
+	d, err := conflictDownload(dir, "/ok_file", got.ConflictSkipSameSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.Skipped() {
+		t.Error("Expecting the same size file download to be skipped")
+	}
+
+	dir = writeExisting(t, "other size")
+
+	if d, err = conflictDownload(dir, "/ok_file", got.ConflictSkipSameSize); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Skipped() {
+		t.Error("Expecting the other size file to be overwritten")
+	}
+
+	if info, _ := os.Stat(d.Path()); info.Size() != okFileStat.Size() {
+		t.Errorf("Expecting size %d, got: %d", okFileStat.Size(), info.Size())
+	}
+}
+
+func conflictRenameTest(t *testing.T) {
+
+	dir := writeExisting(t, "existing file")
+
+	for i, expected := range []string{"file (1)", "file (2)"} {
+
+		d, err := conflictDownload(dir, "/found_and_head_not_allowed", got.ConflictRename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.Path() != filepath.Join(dir, expected) {
+			t.Errorf("Expecting download %d path %s, got: %s", i, expected, d.Path())
+		}
+
+		assertContent(t, d.Path(), "helloworld")
+	}
+
+	assertContent(t, filepath.Join(dir, "file"), "existing file")
+}
+
+func conflictRenameProgressTest(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The chunks are delayed to run the progress func during the download.
+		if r.Header.Get("Range") != "bytes=0-0" {
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		http.ServeContent(w, r, "file", time.Time{}, strings.NewReader("helloworld"))
+	}))
+	defer srv.Close()
+
+	dir := writeExisting(t, "existing file")
+
+	var (
+		mu    sync.Mutex
+		paths = make(map[string]bool)
+	)
+
+	// The progress func reads the path while the file is renamed.
+	g := got.New()
+	g.ProgressFunc = func(d *got.Download) {
+		mu.Lock()
+		paths[d.Path()] = true
+		mu.Unlock()
+	}
+
+	d := &got.Download{
+		URL:            srv.URL + "/file",
+		Dest:           filepath.Join(dir, "file"),
+		ConflictPolicy: got.ConflictRename,
+		Interval:       1,
+	}
+
+	if err := g.Do(d); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Path() != filepath.Join(dir, "file (1)") {
+		t.Errorf("Expecting path file (1), got: %s", d.Path())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(paths) == 0 {
+		t.Error("Expecting progress func calls")
+	}
+
+	assertContent(t, d.Path(), "helloworld")
+}
+
+func conflictFailTest(t *testing.T) {
+
+	dir := writeExisting(t, "existing file")
+
+	for _, path := range []string{"/ok_file", "/found_and_head_not_allowed"} {
+
+		if _, err := conflictDownload(dir, path, got.ConflictFail); !errors.Is(err, got.ErrFileExists) {
+			t.Errorf("Expecting %s ErrFileExists, got: %v", path, err)
+		}
+	}
+
+	assertContent(t, filepath.Join(dir, "file"), "existing file")
+}
+
+func conflictExclusiveTest(t *testing.T) {
+
+	var (
+		wg    sync.WaitGroup
+		dir   = t.TempDir()
+		paths = make([]string, 4)
+	)
+
+	for i := range paths {
+
+		wg.Add(1)
+
+		go func(i int) {
+
+			defer wg.Done()
+
+			d, err := conflictDownload(dir, "/ok_file", got.ConflictRename)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			paths[i] = d.Path()
+		}(i)
+	}
+
+	wg.Wait()
+
+	seen := make(map[string]bool)
+
+	for _, p := range paths {
+
+		if seen[p] {
+			t.Errorf("Expecting unique paths, got: %v", paths)
+		}
+
+		seen[p] = true
+	}
+}
+
+func conflictParseTest(t *testing.T) {
+
+	for _, p := range []got.ConflictPolicy{got.ConflictOverwrite, got.ConflictSkip, got.ConflictSkipSameSize, got.ConflictRename, got.ConflictFail} {
+
+		parsed, err := got.ParseConflictPolicy(p.String())
+		if err != nil || parsed != p {
+			t.Errorf("Expecting %s, got: %v, %v", p, parsed, err)
+		}
+	}
+
+	if _, err := got.ParseConflictPolicy("clobber"); err == nil {
+		t.Error("Expecting unknown policy error")
+	}
+}
@@ -0,0 +1,267 @@
+package got
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar is an http.CookieJar which can be loaded from and saved to
+// Netscape cookies.txt files, the format used by browsers, curl and wget.
+type CookieJar struct {
+	jar *cookiejar.Jar
+
+	mu sync.Mutex
+
+	entries map[string]*cookieEntry
+}
+
+type cookieEntry struct {
+	domain, path, name, value string
+
+	hostOnly, secure, httpOnly bool
+
+	expires time.Time
+}
+
+// NewCookieJar returns a new empty *CookieJar.
+func NewCookieJar() *CookieJar {
+
+	jar, _ := cookiejar.New(nil)
+
+	return &CookieJar{
+		jar:     jar,
+		entries: make(map[string]*cookieEntry),
+	}
+}
+
+// Cookies implements http.CookieJar.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// SetCookies implements http.CookieJar.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+
+	j.jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+
+	for _, c := range cookies {
+
+		e := &cookieEntry{
+			domain:   strings.TrimPrefix(strings.ToLower(c.Domain), "."),
+			path:     c.Path,
+			name:     c.Name,
+			value:    c.Value,
+			secure:   c.Secure,
+			httpOnly: c.HttpOnly,
+			expires:  c.Expires,
+		}
+
+		if e.domain == "" || e.domain == host {
+			e.domain, e.hostOnly = host, c.Domain == ""
+		} else if !strings.HasSuffix(host, "."+e.domain) {
+			// Rejected by the jar.
+			continue
+		}
+
+		if !strings.HasPrefix(e.path, "/") {
+			e.path = defaultCookiePath(u.Path)
+		}
+
+		if c.MaxAge > 0 {
+			e.expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+
+		key := e.key()
+
+		if c.MaxAge < 0 || (!e.expires.IsZero() && e.expires.Before(now)) {
+			delete(j.entries, key)
+			continue
+		}
+
+		j.entries[key] = e
+	}
+}
+
+// Load reads cookies in Netscape format from r.
+func (j *CookieJar) Load(r io.Reader) error {
+
+	scanner := bufio.NewScanner(r)
+
+	for n := 1; scanner.Scan(); n++ {
+
+		line := strings.TrimSpace(scanner.Text())
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+
+		if httpOnly {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+
+		if len(fields) == 6 {
+			// Empty cookie value.
+			fields = append(fields, "")
+		}
+
+		if len(fields) != 7 {
+			return fmt.Errorf("Invalid cookie at line %d", n)
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid cookie expiry at line %d: %s", n, fields[4])
+		}
+
+		var (
+			domain = strings.TrimPrefix(fields[0], ".")
+			secure = strings.EqualFold(fields[3], "TRUE")
+			scheme = "http"
+			cookie = &http.Cookie{
+				Name:     fields[5],
+				Value:    fields[6],
+				Path:     fields[2],
+				Secure:   secure,
+				HttpOnly: httpOnly,
+			}
+		)
+
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = domain
+		}
+
+		if secure {
+			scheme = "https"
+		}
+
+		j.SetCookies(&url.URL{Scheme: scheme, Host: domain, Path: fields[2]}, []*http.Cookie{cookie})
+	}
+
+	return scanner.Err()
+}
+
+// Save writes the jar cookies in Netscape format to w.
+func (j *CookieJar) Save(w io.Writer) error {
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	keys := make([]string, 0, len(j.entries))
+	for key := range j.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if _, err := fmt.Fprint(w, "# Netscape HTTP Cookie File\n\n"); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, key := range keys {
+
+		e := j.entries[key]
+
+		if !e.expires.IsZero() && e.expires.Before(now) {
+			continue
+		}
+
+		domain, expires := e.domain, int64(0)
+
+		if !e.hostOnly {
+			domain = "." + domain
+		}
+
+		if e.httpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+
+		if !e.expires.IsZero() {
+			expires = e.expires.Unix()
+		}
+
+		_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, netscapeBool(!e.hostOnly), e.path, netscapeBool(e.secure), expires, e.name, e.value,
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadFile reads cookies from a Netscape cookies.txt file.
+func (j *CookieJar) LoadFile(name string) error {
+
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return j.Load(file)
+}
+
+// SaveFile writes the jar cookies to a Netscape cookies.txt file.
+func (j *CookieJar) SaveFile(name string) error {
+
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err = j.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func (e *cookieEntry) key() string {
+	return e.domain + ";" + e.path + ";" + e.name
+}
+
+func defaultCookiePath(p string) string {
+
+	if p == "" || p[0] != '/' || strings.Count(p, "/") == 1 {
+		return "/"
+	}
+
+	return path.Dir(p)
+}
+
+func netscapeBool(b bool) string {
+
+	if b {
+		return "TRUE"
+	}
+
+	return "FALSE"
+}
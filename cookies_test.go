@@ -0,0 +1,78 @@
+package got_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/melbahja/got"
+)
+
+func TestCookieJar(t *testing.T) {
+
+	var requests, sessions uint64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		atomic.AddUint64(&requests, 1)
+
+		if c, err := r.Cookie("token"); err != nil || c.Value != "loaded" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		// The probe sets the session, then chunk requests must send it.
+		if c, err := r.Cookie("session"); err == nil && c.Value == "abc" {
+			atomic.AddUint64(&sessions, 1)
+		} else {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/", MaxAge: 3600})
+		}
+
+		http.ServeFile(w, r, "go.mod")
+	}))
+	defer srv.Close()
+
+	jar := got.NewCookieJar()
+
+	if err := jar.Load(strings.NewReader("# Netscape HTTP Cookie File\n127.0.0.1\tFALSE\t/\tFALSE\t0\ttoken\tloaded\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpFile := createTemp()
+	defer clean(tmpFile)
+
+	g := got.New()
+	g.Jar = jar
+
+	if err := g.Do(&got.Download{URL: srv.URL, Dest: tmpFile, ChunkSize: 50}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, s := atomic.LoadUint64(&requests), atomic.LoadUint64(&sessions); s == 0 || s != n-1 {
+		t.Errorf("Expecting %d chunk requests with session cookie but got %d", n-1, s)
+	}
+
+	var buf bytes.Buffer
+
+	if err := jar.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := buf.String()
+
+	if !strings.Contains(saved, "127.0.0.1\tFALSE\t/\tFALSE\t0\ttoken\tloaded\n") {
+		t.Errorf("Loaded cookie not saved:\n%s", saved)
+	}
+
+	if !strings.Contains(saved, "\tsession\tabc\n") {
+		t.Errorf("Session cookie not saved:\n%s", saved)
+	}
+
+	// Saved file can be loaded again.
+	if err := got.NewCookieJar().Load(strings.NewReader(saved)); err != nil {
+		t.Error(err)
+	}
+}
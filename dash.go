@@ -0,0 +1,886 @@
+package got
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DASHConfig selects the representations of MPEG-DASH manifest downloads.
+type DASHConfig struct {
+
+	// Representations are the IDs of the representations to download,
+	// defaults to the highest bandwidth video and audio representations.
+	Representations []string
+}
+
+// ErrNoRepresentation is returned when a DASH representation is not found.
+var ErrNoRepresentation = errors.New("DASH representation not found")
+
+type (
+	mpd struct {
+		Type     string      `xml:"type,attr"`
+		Duration string      `xml:"mediaPresentationDuration,attr"`
+		BaseURL  []string    `xml:"BaseURL"`
+		Periods  []mpdPeriod `xml:"Period"`
+	}
+
+	mpdPeriod struct {
+		Duration       string             `xml:"duration,attr"`
+		BaseURL        []string           `xml:"BaseURL"`
+		AdaptationSets []mpdAdaptationSet `xml:"AdaptationSet"`
+		mpdSegmentInfo
+	}
+
+	mpdAdaptationSet struct {
+		ContentType     string              `xml:"contentType,attr"`
+		MimeType        string              `xml:"mimeType,attr"`
+		BaseURL         []string            `xml:"BaseURL"`
+		Representations []mpdRepresentation `xml:"Representation"`
+		mpdSegmentInfo
+	}
+
+	mpdRepresentation struct {
+		ID        string   `xml:"id,attr"`
+		Bandwidth uint64   `xml:"bandwidth,attr"`
+		MimeType  string   `xml:"mimeType,attr"`
+		BaseURL   []string `xml:"BaseURL"`
+		mpdSegmentInfo
+	}
+
+	// mpdSegmentInfo is inherited from the period and adaptation set by representations.
+	mpdSegmentInfo struct {
+		SegmentTemplate *mpdSegmentTemplate `xml:"SegmentTemplate"`
+		SegmentList     *mpdSegmentList     `xml:"SegmentList"`
+		SegmentBase     *mpdSegmentBase     `xml:"SegmentBase"`
+	}
+
+	mpdSegmentTemplate struct {
+		Media          string       `xml:"media,attr"`
+		Initialization string       `xml:"initialization,attr"`
+		StartNumber    *uint64      `xml:"startNumber,attr"`
+		Timescale      *uint64      `xml:"timescale,attr"`
+		Duration       *uint64      `xml:"duration,attr"`
+		Timeline       *mpdTimeline `xml:"SegmentTimeline"`
+	}
+
+	mpdTimeline struct {
+		S []struct {
+			T *uint64 `xml:"t,attr"`
+			D uint64  `xml:"d,attr"`
+			R int64   `xml:"r,attr"`
+		} `xml:"S"`
+	}
+
+	mpdSegmentList struct {
+		Initialization *mpdURL `xml:"Initialization"`
+		SegmentURLs    []struct {
+			Media      string `xml:"media,attr"`
+			MediaRange string `xml:"mediaRange,attr"`
+		} `xml:"SegmentURL"`
+	}
+
+	mpdSegmentBase struct {
+		IndexRange     string  `xml:"indexRange,attr"`
+		Initialization *mpdURL `xml:"Initialization"`
+	}
+
+	mpdURL struct {
+		SourceURL string `xml:"sourceURL,attr"`
+		Range     string `xml:"range,attr"`
+	}
+
+	// dashTrack is a representation downloaded into its own file.
+	dashTrack struct {
+		path string
+
+		segments []*segment
+	}
+)
+
+// maxDASHSegments is the maximum number of segments of a DASH download.
+const maxDASHSegments = 500000
+
+var mpdIdentifier = regexp.MustCompile(`\$(\w*)(?:%0(\d+)d)?\$`)
+
+// initDASH gets the manifest and expands the selected representations segments.
+func (d *Download) initDASH() error {
+
+	data, base, err := d.getManifest(d.URL)
+	if err != nil {
+		return err
+	}
+
+	var m mpd
+
+	if err = xml.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("Invalid DASH manifest: %w", err)
+	}
+
+	if m.Type == "dynamic" {
+		return errors.New("Live DASH manifests are not supported")
+	}
+
+	if len(m.Periods) == 0 {
+		return errors.New("DASH manifest has no periods")
+	}
+
+	// Only the first period is downloaded.
+	period := &m.Periods[0]
+
+	duration, err := parseISODuration(firstOf(period.Duration, m.Duration))
+	if err != nil {
+		return err
+	}
+
+	if base, err = resolveBase(base, m.BaseURL, period.BaseURL); err != nil {
+		return err
+	}
+
+	// Name the track files after the destination or the manifest.
+	name := d.Dest
+	if name == "" {
+		name = GetFilename(base.String())
+		if name == DefaultFileName {
+			name = GetFilename(d.URL)
+		}
+	}
+	name = filepath.Join(d.Dir, strings.TrimSuffix(name, filepath.Ext(name)))
+
+	selected := make(map[string]bool)
+
+	for _, id := range d.DASH.Representations {
+		selected[id] = true
+	}
+
+	// Without IDs, select the highest bandwidth representation of each content type.
+	if len(selected) == 0 {
+
+		highest := make(map[string]*mpdRepresentation)
+
+		for i := range period.AdaptationSets {
+
+			set := &period.AdaptationSets[i]
+
+			for j := range set.Representations {
+
+				rep := &set.Representations[j]
+				typ := mpdContentType(set, rep)
+
+				if (typ == "video" || typ == "audio") && (highest[typ] == nil || rep.Bandwidth > highest[typ].Bandwidth) {
+					highest[typ] = rep
+				}
+			}
+		}
+
+		for _, rep := range highest {
+			selected[rep.ID] = true
+		}
+	}
+
+	for i := range period.AdaptationSets {
+
+		set := &period.AdaptationSets[i]
+
+		for j := range set.Representations {
+
+			rep := &set.Representations[j]
+
+			if !selected[rep.ID] {
+				continue
+			}
+
+			delete(selected, rep.ID)
+
+			repBase, err := resolveBase(base, set.BaseURL, rep.BaseURL)
+			if err != nil {
+				return err
+			}
+
+			segments, err := d.dashSegments(rep, mergeSegmentInfo(rep.mpdSegmentInfo, set.mpdSegmentInfo, period.mpdSegmentInfo), repBase, duration)
+			if err != nil {
+				return fmt.Errorf("Representation %s: %w", rep.ID, err)
+			}
+
+			d.tracks = append(d.tracks, &dashTrack{
+				path:     name + "." + sanitizeID(rep.ID) + mimeExt(firstOf(rep.MimeType, set.MimeType), mpdContentType(set, rep)),
+				segments: segments,
+			})
+
+			if d.segments = append(d.segments, segments...); len(d.segments) > maxDASHSegments {
+				return fmt.Errorf("DASH download has more than %d segments", maxDASHSegments)
+			}
+		}
+	}
+
+	for id := range selected {
+		return fmt.Errorf("%w: %s", ErrNoRepresentation, id)
+	}
+
+	if len(d.tracks) == 0 {
+		return fmt.Errorf("%w: no video or audio representations", ErrNoRepresentation)
+	}
+
+	d.info = &Info{URL: base.String()}
+	d.path = d.tracks[0].path
+
+	return nil
+}
+
+// startDASH downloads the tracks segments, each track is written to its file.
+func (d *Download) startDASH() error {
+
+	var (
+		files   = make([]*os.File, len(d.tracks))
+		writers = make([]*bufio.Writer, len(d.tracks))
+
+		// tracks maps the segments to their track.
+		tracks = make([]int, 0, len(d.segments))
+	)
+
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+
+	// The track sizes are unknown, the existing track files are kept.
+	policy := d.ConflictPolicy
+	if policy == ConflictSkipSameSize {
+		policy = ConflictSkip
+	}
+
+	var (
+		segments []*segment
+		skipped  int
+	)
+
+	for i, t := range d.tracks {
+
+		f, path, err := d.createPolicyFile(t.path, policy)
+		if err != nil {
+			return err
+		}
+
+		d.pathMu.Lock()
+		t.path = path
+		d.pathMu.Unlock()
+
+		// The existing track is skipped, the other tracks are downloaded.
+		if f == nil {
+			skipped++
+			continue
+		}
+
+		files[i], writers[i] = f, bufio.NewWriter(f)
+
+		for range t.segments {
+			tracks = append(tracks, i)
+		}
+
+		segments = append(segments, t.segments...)
+	}
+
+	// The first track file is the download path.
+	d.setPath(d.tracks[0].path)
+
+	if skipped == len(d.tracks) {
+		d.skipped = true
+		return nil
+	}
+
+	err := d.downloadSegments(segments, func(i int, data []byte) error {
+		_, err := writers[tracks[i]].Write(data)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, w := range writers {
+
+		if w == nil {
+			continue
+		}
+
+		if err = w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TrackPaths returns the track files of DASH downloads.
+func (d *Download) TrackPaths() []string {
+
+	d.pathMu.Lock()
+	defer d.pathMu.Unlock()
+
+	paths := make([]string, len(d.tracks))
+
+	for i, t := range d.tracks {
+		paths[i] = t.path
+	}
+
+	return paths
+}
+
+// dashSegments expands the representation segments.
+func (d *Download) dashSegments(rep *mpdRepresentation, info mpdSegmentInfo, base *url.URL, duration float64) ([]*segment, error) {
+
+	switch {
+
+	case info.SegmentTemplate != nil:
+		return expandTemplate(info.SegmentTemplate, rep, base, duration)
+
+	case info.SegmentList != nil:
+
+		var (
+			segments []*segment
+			list     = info.SegmentList
+		)
+
+		if list.Initialization != nil {
+
+			s, err := newDASHSegment(base, list.Initialization.SourceURL, list.Initialization.Range)
+			if err != nil {
+				return nil, err
+			}
+
+			segments = append(segments, s)
+		}
+
+		for _, u := range list.SegmentURLs {
+
+			s, err := newDASHSegment(base, u.Media, u.MediaRange)
+			if err != nil {
+				return nil, err
+			}
+
+			segments = append(segments, s)
+		}
+
+		return segments, nil
+
+	case info.SegmentBase != nil && info.SegmentBase.IndexRange != "":
+		return d.indexSegments(info.SegmentBase, base)
+	}
+
+	// The representation is a single file.
+	return []*segment{{URL: base.String()}}, nil
+}
+
+// indexSegments returns the segments of the SegmentBase sidx index.
+func (d *Download) indexSegments(sb *mpdSegmentBase, base *url.URL) ([]*segment, error) {
+
+	index, err := parseRange(sb.IndexRange)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := d.getRange(base.String(), index, RequestKind{Type: ProbeRequest}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes, offset, err := parseSidx(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The init segment includes everything before the first media segment.
+	start := index.Start + offset
+
+	if start < offset {
+		return nil, errors.New("Invalid sidx box offset")
+	}
+
+	segments := []*segment{{URL: base.String(), Range: &Chunk{Start: 0, End: start - 1}}}
+
+	for _, size := range sizes {
+
+		if start+size < start {
+			return nil, errors.New("Invalid sidx box offset")
+		}
+
+		segments = append(segments, &segment{URL: base.String(), Range: &Chunk{Start: start, End: start + size - 1}})
+		start += size
+	}
+
+	return segments, nil
+}
+
+// parseSidx returns the referenced sizes of a sidx box, and the offset of the first
+// referenced byte from the box start.
+func parseSidx(data []byte) ([]uint64, uint64, error) {
+
+	invalid := errors.New("Invalid sidx box")
+
+	if len(data) < 8 {
+		return nil, 0, invalid
+	}
+
+	size, typ, header := uint64(binary.BigEndian.Uint32(data)), string(data[4:8]), 8
+
+	if size == 1 {
+
+		if len(data) < 16 {
+			return nil, 0, invalid
+		}
+
+		size, header = binary.BigEndian.Uint64(data[8:]), 16
+
+	} else if size == 0 {
+
+		// The box extends to the end of the data.
+		size = uint64(len(data))
+	}
+
+	if typ != "sidx" || size < uint64(header) || size > uint64(len(data)) {
+		return nil, 0, invalid
+	}
+
+	b := data[header:size]
+
+	if len(b) < 12 {
+		return nil, 0, invalid
+	}
+
+	version := b[0]
+	b = b[12:] // version, flags, reference_ID and timescale.
+
+	var firstOffset uint64
+
+	if version == 0 {
+
+		if len(b) < 8 {
+			return nil, 0, invalid
+		}
+
+		firstOffset, b = uint64(binary.BigEndian.Uint32(b[4:])), b[8:]
+
+	} else {
+
+		if len(b) < 16 {
+			return nil, 0, invalid
+		}
+
+		firstOffset, b = binary.BigEndian.Uint64(b[8:]), b[16:]
+	}
+
+	if len(b) < 4 {
+		return nil, 0, invalid
+	}
+
+	count := int(binary.BigEndian.Uint16(b[2:]))
+	b = b[4:]
+
+	if len(b) < count*12 {
+		return nil, 0, invalid
+	}
+
+	// The offset and the referenced bytes must fit in the uint64 ranges.
+	if firstOffset > math.MaxUint64-size {
+		return nil, 0, invalid
+	}
+
+	var (
+		sizes = make([]uint64, count)
+		end   = size + firstOffset
+	)
+
+	for i := range sizes {
+
+		ref := binary.BigEndian.Uint32(b[i*12:])
+
+		if ref>>31 == 1 {
+			return nil, 0, errors.New("Hierarchical sidx boxes are not supported")
+		}
+
+		if sizes[i] = uint64(ref & 0x7fffffff); sizes[i] == 0 || end > math.MaxUint64-sizes[i] {
+			return nil, 0, invalid
+		}
+
+		end += sizes[i]
+	}
+
+	return sizes, size + firstOffset, nil
+}
+
+// expandTemplate returns the segments of a SegmentTemplate.
+func expandTemplate(t *mpdSegmentTemplate, rep *mpdRepresentation, base *url.URL, duration float64) ([]*segment, error) {
+
+	var (
+		segments  []*segment
+		number    uint64 = 1
+		timescale uint64 = 1
+	)
+
+	if t.StartNumber != nil {
+		number = *t.StartNumber
+	}
+
+	if t.Timescale != nil && *t.Timescale > 0 {
+		timescale = *t.Timescale
+	}
+
+	tooMany := fmt.Errorf("SegmentTemplate has more than %d segments", maxDASHSegments)
+
+	add := func(tmpl string, number, time uint64) error {
+
+		s, err := newDASHSegment(base, expandIdentifiers(tmpl, rep, number, time), "")
+		if err == nil {
+			segments = append(segments, s)
+		}
+
+		return err
+	}
+
+	if t.Initialization != "" {
+		if err := add(t.Initialization, number, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.Media == "" {
+		return segments, nil
+	}
+
+	if t.Timeline != nil {
+
+		var time uint64
+
+		for i, s := range t.Timeline.S {
+
+			if s.T != nil {
+				time = *s.T
+			}
+
+			if s.D == 0 {
+				return nil, errors.New("Invalid SegmentTimeline duration")
+			}
+
+			repeat := s.R
+
+			// A negative repeat count repeats until the next S element or the period end.
+			if repeat < 0 {
+
+				end := uint64(duration * float64(timescale))
+				if i+1 < len(t.Timeline.S) && t.Timeline.S[i+1].T != nil {
+					end = *t.Timeline.S[i+1].T
+				}
+
+				if end <= time {
+					return nil, errors.New("Invalid SegmentTimeline repeat without end")
+				}
+
+				n := math.Ceil(float64(end-time) / float64(s.D))
+				if n > maxDASHSegments {
+					return nil, tooMany
+				}
+
+				repeat = int64(n) - 1
+			}
+
+			if repeat >= maxDASHSegments-int64(len(segments)) {
+				return nil, tooMany
+			}
+
+			for j := int64(0); j <= repeat; j++ {
+
+				if err := add(t.Media, number, time); err != nil {
+					return nil, err
+				}
+
+				time += s.D
+				number++
+			}
+		}
+
+		return segments, nil
+	}
+
+	if t.Duration == nil || *t.Duration == 0 || duration == 0 {
+		return nil, errors.New("SegmentTemplate without timeline requires the segment and period durations")
+	}
+
+	count := math.Ceil(duration * float64(timescale) / float64(*t.Duration))
+
+	if count > maxDASHSegments {
+		return nil, tooMany
+	}
+
+	for i := uint64(0); i < uint64(count); i++ {
+		if err := add(t.Media, number+i, i**t.Duration); err != nil {
+			return nil, err
+		}
+	}
+
+	return segments, nil
+}
+
+// expandIdentifiers replaces the template identifiers, e.g. $Number%05d$.
+func expandIdentifiers(tmpl string, rep *mpdRepresentation, number, time uint64) string {
+
+	return mpdIdentifier.ReplaceAllStringFunc(tmpl, func(s string) string {
+
+		m := mpdIdentifier.FindStringSubmatch(s)
+
+		var value string
+
+		switch m[1] {
+		case "":
+			return "$"
+		case "RepresentationID":
+			return rep.ID
+		case "Number":
+			value = strconv.FormatUint(number, 10)
+		case "Bandwidth":
+			value = strconv.FormatUint(rep.Bandwidth, 10)
+		case "Time":
+			value = strconv.FormatUint(time, 10)
+		default:
+			return s
+		}
+
+		if width, _ := strconv.Atoi(m[2]); len(value) < width {
+			value = strings.Repeat("0", width-len(value)) + value
+		}
+
+		return value
+	})
+}
+
+func newDASHSegment(base *url.URL, ref, byteRange string) (*segment, error) {
+
+	URL := base.String()
+
+	if ref != "" {
+
+		var err error
+
+		if URL, err = resolveURL(base, ref); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &segment{URL: URL}
+
+	if byteRange != "" {
+
+		r, err := parseRange(byteRange)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Range = r
+	}
+
+	return s, nil
+}
+
+// parseRange parses a "first-last" bytes range.
+func parseRange(s string) (*Chunk, error) {
+
+	first, last, ok := strings.Cut(s, "-")
+
+	start, err1 := strconv.ParseUint(strings.TrimSpace(first), 10, 64)
+	end, err2 := strconv.ParseUint(strings.TrimSpace(last), 10, 64)
+
+	if !ok || err1 != nil || err2 != nil || end < start {
+		return nil, fmt.Errorf("Invalid byte range: %s", s)
+	}
+
+	return &Chunk{Start: start, End: end}, nil
+}
+
+// mergeSegmentInfo returns the segment info inherited from the parents, the most specific
+// SegmentTemplate or SegmentList is used, and the template attributes are inherited.
+func mergeSegmentInfo(infos ...mpdSegmentInfo) mpdSegmentInfo {
+
+	var merged mpdSegmentInfo
+
+	for _, info := range infos {
+
+		if merged.SegmentBase == nil {
+			merged.SegmentBase = info.SegmentBase
+		}
+
+		if merged.SegmentList == nil && merged.SegmentTemplate == nil {
+			merged.SegmentList = info.SegmentList
+		}
+
+		if info.SegmentTemplate == nil || merged.SegmentList != nil {
+			continue
+		}
+
+		if merged.SegmentTemplate == nil {
+			t := *info.SegmentTemplate
+			merged.SegmentTemplate = &t
+			continue
+		}
+
+		t, parent := merged.SegmentTemplate, info.SegmentTemplate
+
+		if t.Media == "" {
+			t.Media = parent.Media
+		}
+		if t.Initialization == "" {
+			t.Initialization = parent.Initialization
+		}
+		if t.StartNumber == nil {
+			t.StartNumber = parent.StartNumber
+		}
+		if t.Timescale == nil {
+			t.Timescale = parent.Timescale
+		}
+		if t.Duration == nil {
+			t.Duration = parent.Duration
+		}
+		if t.Timeline == nil {
+			t.Timeline = parent.Timeline
+		}
+	}
+
+	return merged
+}
+
+// resolveBase resolves the first BaseURL of each level.
+func resolveBase(base *url.URL, levels ...[]string) (*url.URL, error) {
+
+	for _, l := range levels {
+
+		if len(l) == 0 || strings.TrimSpace(l[0]) == "" {
+			continue
+		}
+
+		u, err := url.Parse(strings.TrimSpace(l[0]))
+		if err != nil {
+			return nil, err
+		}
+
+		base = base.ResolveReference(u)
+	}
+
+	return base, nil
+}
+
+func mpdContentType(set *mpdAdaptationSet, rep *mpdRepresentation) string {
+
+	if set.ContentType != "" {
+		return set.ContentType
+	}
+
+	typ, _, _ := strings.Cut(firstOf(rep.MimeType, set.MimeType), "/")
+
+	return typ
+}
+
+// mimeExt returns the track file extension.
+func mimeExt(mimeType, contentType string) string {
+
+	switch mimeType {
+	case "audio/mp4":
+		return ".m4a"
+	case "video/webm", "audio/webm":
+		return ".webm"
+	case "text/vtt":
+		return ".vtt"
+	case "video/mp2t":
+		return ".ts"
+	}
+
+	if contentType == "text" {
+		return ".txt"
+	}
+
+	return ".mp4"
+}
+
+// sanitizeID makes a representation ID safe for file names.
+func sanitizeID(id string) string {
+
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, id)
+}
+
+// parseISODuration returns the seconds of an ISO 8601 duration, e.g. PT1H2M3.5S.
+func parseISODuration(s string) (float64, error) {
+
+	if s == "" {
+		return 0, nil
+	}
+
+	if !strings.HasPrefix(s, "P") {
+		return 0, fmt.Errorf("Invalid duration: %s", s)
+	}
+
+	var (
+		seconds float64
+		number  string
+		inTime  bool
+	)
+
+	for _, c := range s[1:] {
+
+		if c >= '0' && c <= '9' || c == '.' {
+			number += string(c)
+			continue
+		}
+
+		if c == 'T' {
+			inTime = true
+			continue
+		}
+
+		v, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid duration: %s", s)
+		}
+
+		number = ""
+
+		switch {
+		case c == 'Y':
+			v *= 365 * 86400
+		case c == 'M' && !inTime:
+			v *= 30 * 86400
+		case c == 'W':
+			v *= 7 * 86400
+		case c == 'D':
+			v *= 86400
+		case c == 'H':
+			v *= 3600
+		case c == 'M':
+			v *= 60
+		case c == 'S':
+		default:
+			return 0, fmt.Errorf("Invalid duration: %s", s)
+		}
+
+		seconds += v
+	}
+
+	if number != "" {
+		return 0, fmt.Errorf("Invalid duration: %s", s)
+	}
+
+	return seconds, nil
+}
@@ -0,0 +1,145 @@
+package got
+
+import (
+	"encoding/binary"
+	"encoding/xml"
+	"math"
+	"net/url"
+	"testing"
+)
+
+func TestParseSidx(t *testing.T) {
+
+	box := func(size uint32, largesize uint64, length int) []byte {
+
+		b := make([]byte, length)
+		binary.BigEndian.PutUint32(b, size)
+		copy(b[4:], "sidx")
+
+		if size == 1 {
+			binary.BigEndian.PutUint64(b[8:], largesize)
+		}
+
+		return b
+	}
+
+	// refs returns a version 1 box with the first offset and the referenced sizes.
+	refs := func(firstOffset uint64, sizes ...uint32) []byte {
+
+		b := make([]byte, 40+12*len(sizes))
+		binary.BigEndian.PutUint32(b, uint32(len(b)))
+		copy(b[4:], "sidx")
+		b[8] = 1
+		binary.BigEndian.PutUint64(b[28:], firstOffset)
+		binary.BigEndian.PutUint16(b[38:], uint16(len(sizes)))
+
+		for i, size := range sizes {
+			binary.BigEndian.PutUint32(b[40+i*12:], size)
+		}
+
+		return b
+	}
+
+	tests := []struct {
+		data  []byte
+		valid bool
+	}{
+		{box(0, 0, 32), true},
+		{box(32, 0, 32), true},
+		{box(1, 40, 40), true},
+		{box(0, 0, 20), false},
+		{box(4, 0, 32), false},
+		{box(7, 0, 32), false},
+		{box(1, 8, 32), false},
+		{box(1, 15, 32), false},
+		{box(1, 0, 32), false},
+		{box(64, 0, 32), false},
+		{box(1, 1<<63, 32), false},
+		{[]byte("sidx"), false},
+		{refs(4, 100, 200), true},
+		{refs(math.MaxUint64-53, 1), true},
+		{refs(math.MaxUint64), false},
+		{refs(math.MaxUint64-52, 1), false},
+		{refs(4, 100, 0), false},
+		{refs(4, 0x80000000), false},
+	}
+
+	for i, test := range tests {
+
+		if _, _, err := parseSidx(test.data); (err == nil) != test.valid {
+			t.Errorf("Expecting test %d valid %v, got: %v", i, test.valid, err)
+		}
+	}
+}
+
+func TestExpandTemplateRepeat(t *testing.T) {
+
+	base, _ := url.Parse("http://example.com/")
+
+	tests := []struct {
+		timeline string
+		duration float64
+		segments int
+	}{
+		{`<S t="0" d="10" r="-1"/>`, 3, 3},
+		{`<S t="0" d="10" r="-1"/><S t="20" d="5"/>`, 0, 3},
+		{`<S t="10" d="10" r="-1"/>`, 0, 0},
+		{`<S t="0" d="10" r="-1"/>`, 0, 0},
+		{`<S t="40" d="10" r="-1"/>`, 3, 0},
+		{`<S t="20" d="10" r="-1"/><S t="10" d="5"/>`, 3, 0},
+		{`<S t="0" d="0"/>`, 3, 0},
+	}
+
+	for _, test := range tests {
+
+		var tmpl mpdSegmentTemplate
+
+		data := `<SegmentTemplate media="$Time$.m4s" timescale="10"><SegmentTimeline>` + test.timeline + `</SegmentTimeline></SegmentTemplate>`
+
+		if err := xml.Unmarshal([]byte(data), &tmpl); err != nil {
+			t.Fatal(err)
+		}
+
+		segments, err := expandTemplate(&tmpl, &mpdRepresentation{}, base, test.duration)
+
+		if test.segments == 0 {
+
+			if err == nil {
+				t.Errorf("Expecting %s error, got %d segments", test.timeline, len(segments))
+			}
+
+			continue
+		}
+
+		if err != nil || len(segments) != test.segments {
+			t.Errorf("Expecting %s %d segments, got: %d, %v", test.timeline, test.segments, len(segments), err)
+		}
+	}
+}
+
+func TestExpandTemplateLimit(t *testing.T) {
+
+	base, _ := url.Parse("http://example.com/")
+
+	tests := []struct {
+		template string
+		duration float64
+	}{
+		{`<SegmentTemplate media="$Number$.m4s"><SegmentTimeline><S d="1" r="2000000000"/></SegmentTimeline></SegmentTemplate>`, 0},
+		{`<SegmentTemplate media="$Number$.m4s" duration="1"/>`, 1e12},
+		{`<SegmentTemplate media="$Number$.m4s"><SegmentTimeline><S t="0" d="1" r="-1"/></SegmentTimeline></SegmentTemplate>`, 1e19},
+	}
+
+	for _, test := range tests {
+
+		var tmpl mpdSegmentTemplate
+
+		if err := xml.Unmarshal([]byte(test.template), &tmpl); err != nil {
+			t.Fatal(err)
+		}
+
+		if segments, err := expandTemplate(&tmpl, &mpdRepresentation{}, base, test.duration); err == nil {
+			t.Errorf("Expecting %s error, got %d segments", test.template, len(segments))
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package got_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/melbahja/got"
+)
+
+const testMPD = `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" mediaPresentationDuration="PT6S">
+  <BaseURL>media/</BaseURL>
+  <Period>
+    <AdaptationSet contentType="video" mimeType="video/mp4">
+      <SegmentTemplate media="$RepresentationID$/seg-$Number%%03d$.m4s" initialization="$RepresentationID$/init.mp4" timescale="1000">
+        <SegmentTimeline>
+          <S t="0" d="2000" r="1"/>
+          <S d="2000" r="-1"/>
+        </SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="v1" bandwidth="1000000"/>
+      <Representation id="v2" bandwidth="5000000"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4">
+      <Representation id="a1" bandwidth="128000">
+        <SegmentTemplate media="audio/$Time$.m4s" initialization="audio/init.mp4" duration="2" startNumber="0"/>
+      </Representation>
+      <Representation id="a2" bandwidth="64000">
+        <SegmentList>
+          <Initialization sourceURL="list.mp4" range="0-9"/>
+          <SegmentURL media="list.mp4" mediaRange="10-99"/>
+          <SegmentURL media="list.mp4" mediaRange="100-%d"/>
+        </SegmentList>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet mimeType="video/webm">
+      <Representation id="v3" bandwidth="500000">
+        <BaseURL>base.webm</BaseURL>
+        <SegmentBase indexRange="20-%d">
+          <Initialization range="0-19"/>
+        </SegmentBase>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+func TestDASH(t *testing.T) {
+
+	content, err := os.ReadFile("go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string][]byte)
+	parts := splitBytes(content, 4)
+
+	for _, id := range []string{"v1", "v2"} {
+		files["/media/"+id+"/init.mp4"] = []byte(id + ":" + string(parts[0]))
+		for i := 1; i <= 3; i++ {
+			files[fmt.Sprintf("/media/%s/seg-%03d.m4s", id, i)] = parts[i]
+		}
+	}
+
+	files["/media/audio/init.mp4"] = parts[0]
+	files["/media/audio/0.m4s"] = parts[1]
+	files["/media/audio/2.m4s"] = parts[2]
+	files["/media/audio/4.m4s"] = parts[3]
+	files["/media/list.mp4"] = content
+
+	// base.webm has 20 init bytes, then the sidx box and the referenced segments.
+	sidx := sidxBox(4, []uint32{100, uint32(len(content) - 100)})
+	base := append(append(append([]byte("init bytes 12345678."), sidx...), "gap."...), content...)
+	files["/media/base.webm"] = base
+
+	files["/manifest.mpd"] = []byte(fmt.Sprintf(testMPD, len(content)-1, 20+len(sidx)-1))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		data, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		http.ServeContent(w, r, "", time.Now(), bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	t.Run("defaultTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		d := &got.Download{URL: srv.URL + "/manifest.mpd", Dir: dir, DASH: &got.DASHConfig{}, Concurrency: 3}
+
+		if err := got.New().Do(d); err != nil {
+			t.Fatal(err)
+		}
+
+		expectFiles(t, d, map[string][]byte{
+			filepath.Join(dir, "manifest.v2.mp4"): append([]byte("v2:"), content...),
+			filepath.Join(dir, "manifest.a1.m4a"): content,
+		})
+	})
+
+	t.Run("representationsTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		d := &got.Download{URL: srv.URL + "/manifest.mpd", Dir: dir, Dest: "out.mp4", DASH: &got.DASHConfig{Representations: []string{"v3", "a2"}}}
+
+		if err := got.New().Do(d); err != nil {
+			t.Fatal(err)
+		}
+
+		expectFiles(t, d, map[string][]byte{
+			filepath.Join(dir, "out.a2.m4a"):  content,
+			filepath.Join(dir, "out.v3.webm"): base,
+		})
+	})
+
+	t.Run("conflictTest", func(t *testing.T) {
+
+		for _, policy := range []got.ConflictPolicy{got.ConflictSkip, got.ConflictSkipSameSize} {
+
+			dir := t.TempDir()
+			video, audio := filepath.Join(dir, "manifest.v2.mp4"), filepath.Join(dir, "manifest.a1.m4a")
+
+			if err := os.WriteFile(audio, []byte("existing"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// The missing video track is downloaded, the existing audio track is kept.
+			d := &got.Download{URL: srv.URL + "/manifest.mpd", Dir: dir, DASH: &got.DASHConfig{}, ConflictPolicy: policy}
+
+			if err := got.New().Do(d); err != nil {
+				t.Fatal(err)
+			}
+
+			if d.Skipped() {
+				t.Errorf("Expecting %s partial tracks to not be skipped", policy)
+			}
+
+			expectFiles(t, d, map[string][]byte{
+				video: append([]byte("v2:"), content...),
+				audio: []byte("existing"),
+			})
+
+			// All the tracks exist.
+			d = &got.Download{URL: srv.URL + "/manifest.mpd", Dir: dir, DASH: &got.DASHConfig{}, ConflictPolicy: policy}
+
+			if err := got.New().Do(d); err != nil {
+				t.Fatal(err)
+			}
+
+			if !d.Skipped() {
+				t.Errorf("Expecting %s existing tracks to be skipped", policy)
+			}
+		}
+
+		dir := t.TempDir()
+
+		if err := os.WriteFile(filepath.Join(dir, "manifest.a1.m4a"), []byte("existing"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		d := &got.Download{URL: srv.URL + "/manifest.mpd", Dir: dir, DASH: &got.DASHConfig{}, ConflictPolicy: got.ConflictFail}
+
+		if err := got.New().Do(d); !errors.Is(err, got.ErrFileExists) {
+			t.Errorf("Expecting ErrFileExists, but got %v", err)
+		}
+	})
+
+	t.Run("notFoundTest", func(t *testing.T) {
+
+		d := &got.Download{URL: srv.URL + "/manifest.mpd", Dir: t.TempDir(), DASH: &got.DASHConfig{Representations: []string{"v4"}}}
+
+		if err := got.New().Do(d); !errors.Is(err, got.ErrNoRepresentation) {
+			t.Errorf("Expecting ErrNoRepresentation, but got %v", err)
+		}
+	})
+}
+
+func expectFiles(t *testing.T, d *got.Download, expect map[string][]byte) {
+
+	if len(d.TrackPaths()) != len(expect) {
+		t.Errorf("Expecting %d tracks, but got %v", len(expect), d.TrackPaths())
+	}
+
+	for _, path := range d.TrackPaths() {
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if e, ok := expect[path]; !ok || !bytes.Equal(data, e) {
+			t.Errorf("Unexpected track file %s:\n%s", path, data)
+		}
+	}
+}
+
+// sidxBox returns a version 0 sidx box referencing media segments.
+func sidxBox(firstOffset uint32, sizes []uint32) []byte {
+
+	b := make([]byte, 32+12*len(sizes))
+
+	binary.BigEndian.PutUint32(b, uint32(len(b)))
+	copy(b[4:], "sidx")
+	binary.BigEndian.PutUint32(b[12:], 1)    // reference_ID
+	binary.BigEndian.PutUint32(b[16:], 1000) // timescale
+	binary.BigEndian.PutUint32(b[24:], firstOffset)
+	binary.BigEndian.PutUint16(b[30:], uint16(len(sizes)))
+
+	for i, size := range sizes {
+		binary.BigEndian.PutUint32(b[32+i*12:], size)
+	}
+
+	return b
+}
@@ -2,14 +2,10 @@ package got
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"runtime"
-	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -21,8 +17,20 @@ type (
 	Info struct {
 		Size      uint64
 		Rangeable bool
+
+		// URL is the final URL after following redirects.
+		URL string
+
+		// Redirects is the redirect chain, it ends with the final URL.
+		Redirects []string
+
+		// ETag is the resource entity tag if any.
+		ETag string
 	}
 
+	// URLProvider returns a fresh download URL, e.g. a new pre-signed URL.
+	URLProvider func(ctx context.Context) (string, error)
+
 	// ProgressFunc to show progress state, called by RunProgress based on interval.
 	ProgressFunc func(d *Download)
 
@@ -38,14 +46,104 @@ type (
 
 		Header []GotHeader
 
+		// Method is the request method, defaults to GET.
+		Method string
+
+		// Body is sent with every request, GetBody can be used instead to return a new body reader.
+		Body []byte
+
+		GetBody func() (io.ReadCloser, error)
+
+		// RequestHeader values are added to every request after Header, a key replaces
+		// the default values, a key without values removes the header,
+		// and Host sets the request host.
+		RequestHeader http.Header
+
+		// Auth is the authentication used for requests sent to the URL origin.
+		Auth *Auth
+
+		// MaxRedirects is the maximum number of redirects to follow, defaults to 10,
+		// a negative value disables redirects, the redirect response fails with its status code.
+		MaxRedirects int
+
+		// AllowDowngrade allows redirects from https to http URLs.
+		AllowDowngrade bool
+
+		// URLProvider is called when a chunk request gets 401 or 403 status,
+		// the chunk is retried using the new URL if it has the same size and ETag.
+		URLProvider URLProvider
+
+		// RequestHook is called for the probe and every chunk request.
+		RequestHook RequestHook
+
+		// Jar is the cookie jar used for the probe and chunk requests.
+		Jar http.CookieJar
+
+		// TLS options applied to the Client transport.
+		TLS *TLSConfig
+
+		// S3 options of s3://bucket/key URLs.
+		S3 *S3Config
+
+		// HLS downloads the URL as an HLS playlist when set,
+		// the playlist segments are concatenated into the download file.
+		HLS *HLSConfig
+
+		// DASH downloads the URL as an MPEG-DASH manifest when set,
+		// each selected representation is written to its own file.
+		DASH *DASHConfig
+
+		// Logger logs the probe results, chunk plans, requests, retries and errors.
+		Logger Logger
+
+		// ConflictPolicy is the action taken when the file exists, defaults to overwrite.
+		ConflictPolicy ConflictPolicy
+
+		// ServerName is how the Content-Disposition file name is used, defaults to prefer it over the URL name.
+		ServerName ServerNamePolicy
+
 		StopProgress bool
 
 		path string
 
+		// pathMu guards path and the DASH track paths, they change when the file is renamed
+		// while ProgressFunc reads them.
+		pathMu sync.Mutex
+
 		unsafeName string
 
+		// finalURL is the URL resolved by the probe request.
+		finalURL string
+
+		// finalMethod is the method of the final URL, it changes when a redirect
+		// turns a POST into a GET.
+		finalMethod string
+
+		// urlGen is incremented when finalURL is refreshed.
+		urlGen uint
+
+		urlMu sync.Mutex
+
 		ctx context.Context
 
+		client *http.Client
+
+		clientErr error
+
+		clientOnce sync.Once
+
+		// s3Object is the S3 object resolved by the probe.
+		s3Object *s3Object
+
+		// segments are the media segments of HLS and DASH downloads.
+		segments []*segment
+
+		// skipped is set when the file exists and the ConflictPolicy skips the download.
+		skipped bool
+
+		// tracks are the representation files of DASH downloads.
+		tracks []*dashTrack
+
 		size, lastSize uint64
 
 		info *Info
@@ -61,61 +159,46 @@ type (
 	}
 )
 
-// Try downloading the first byte of the file using a range request.
-// If the server supports range requests, then we'll extract the length info from content-range,
-// Otherwise this just downloads the whole file in one go
+// GetInfoOrDownload gets the file info using the URL scheme protocol.
+// If the file is not rangeable, then this just downloads the whole file in one go.
 func (d *Download) GetInfoOrDownload() (*Info, error) {
 
-	var (
-		err  error
-		dest *os.File
-		req  *http.Request
-		res  *http.Response
-	)
-
-	if req, err = NewRequest(d.ctx, "GET", d.URL, append(d.Header, GotHeader{"Range", "bytes=0-0"})); err != nil {
+	p, err := getProtocol(d.URL)
+	if err != nil {
 		return &Info{}, err
 	}
 
-	if res, err = d.Client.Do(req); err != nil {
+	info, body, err := p.Probe(d)
+	if err != nil {
 		return &Info{}, err
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode >= 300 {
-		return &Info{}, fmt.Errorf("Response status code is not ok: %d", res.StatusCode)
+	if info.Rangeable {
+		return info, nil
 	}
 
-	// Set content disposition non trusted name
-	d.unsafeName = res.Header.Get("content-disposition")
-
-	if dest, err = os.Create(d.Path()); err != nil {
-		return &Info{}, err
+	dest, err := d.createDownloadFile(info.Size)
+	if err != nil || dest == nil {
+		if body != nil {
+			body.Close()
+		}
+		if err != nil {
+			return &Info{}, err
+		}
+		return info, nil
 	}
 	defer dest.Close()
 
-	if _, err = io.Copy(dest, io.TeeReader(res.Body, d)); err != nil {
-		return &Info{}, err
+	if body == nil {
+		return info, nil
 	}
+	defer body.Close()
 
-	// Get content length from content-range response header,
-	// if content-range exists, that means partial content is supported.
-	if cr := res.Header.Get("content-range"); cr != "" && res.ContentLength == 1 {
-		l := strings.Split(cr, "/")
-		if len(l) == 2 {
-			if length, err := strconv.ParseUint(l[1], 10, 64); err == nil {
-
-				return &Info{
-					Size:      length,
-					Rangeable: true,
-				}, nil
-			}
-		}
-		// Make sure the caller knows about the problem and we don't just silently fail
-		return &Info{}, fmt.Errorf("Response includes content-range header which is invalid: %s", cr)
+	if _, err = io.Copy(dest, io.TeeReader(body, d)); err != nil {
+		return &Info{}, err
 	}
 
-	return &Info{}, nil
+	return info, nil
 }
 
 // Init set defaults and split file into chunks and gets Info,
@@ -125,6 +208,12 @@ func (d *Download) Init() (err error) {
 	// Set start time.
 	d.startedAt = time.Now()
 
+	defer func() {
+		if err != nil {
+			d.log(LogError, "download init failed", "url", d.URL, "error", err)
+		}
+	}()
+
 	// Set default client.
 	if d.Client == nil {
 		d.Client = DefaultClient
@@ -135,19 +224,31 @@ func (d *Download) Init() (err error) {
 		d.ctx = context.Background()
 	}
 
+	// Set concurrency default.
+	if d.Concurrency == 0 {
+		d.Concurrency = getDefaultConcurrency()
+	}
+
+	// Get the HLS playlist segments.
+	if d.HLS != nil {
+		return d.initHLS()
+	}
+
+	// Get the manifest representations segments.
+	if d.DASH != nil {
+		return d.initDASH()
+	}
+
 	// Get URL info and partial content support state
 	if d.info, err = d.GetInfoOrDownload(); err != nil {
 		return err
 	}
 
-	// Partial content not supported, and the file downladed.
-	if d.info.Rangeable == false {
-		return nil
-	}
+	d.log(LogInfo, "probe", "url", d.URL, "final_url", d.FinalURL(), "size", d.info.Size, "rangeable", d.info.Rangeable, "path", d.Path())
 
-	// Set concurrency default.
-	if d.Concurrency == 0 {
-		d.Concurrency = getDefaultConcurrency()
+	// Partial content not supported, and the file downladed or skipped.
+	if d.info.Rangeable == false || d.skipped {
+		return nil
 	}
 
 	// Set default chunk size
@@ -155,6 +256,11 @@ func (d *Download) Init() (err error) {
 		d.ChunkSize = getDefaultChunkSize(d.info.Size, d.MinChunkSize, d.MaxChunkSize, uint64(d.Concurrency))
 	}
 
+	// Files smaller than 2 bytes are downloaded in a single chunk.
+	if d.ChunkSize == 0 {
+		d.ChunkSize = 1
+	}
+
 	chunksLen := d.info.Size / d.ChunkSize
 	d.chunks = make([]*Chunk, 0, chunksLen)
 
@@ -173,14 +279,35 @@ func (d *Download) Init() (err error) {
 		}
 	}
 
+	d.log(LogDebug, "chunks", "url", d.URL, "chunks", len(d.chunks), "chunk_size", d.ChunkSize, "concurrency", d.Concurrency)
+
 	return nil
 }
 
 // Start downloads the file chunks, and merges them.
 // Must be called only after init
 func (d *Download) Start() (err error) {
-	// If the file was already downloaded during GetInfoOrDownload, then there will be no chunks
-	if d.info.Rangeable == false {
+
+	defer func() {
+		switch {
+		case err != nil:
+			d.log(LogError, "download failed", "url", d.URL, "error", err)
+		case d.skipped:
+		default:
+			d.log(LogInfo, "download complete", "url", d.URL, "path", d.Path(), "size", d.Size(), "duration", d.TotalCost())
+		}
+	}()
+
+	if d.HLS != nil {
+		return d.startHLS()
+	}
+
+	if d.DASH != nil {
+		return d.startDASH()
+	}
+
+	// If the file was already downloaded or skipped during GetInfoOrDownload, then there will be no chunks
+	if d.info.Rangeable == false || d.skipped {
 		select {
 		case <-d.ctx.Done():
 			return d.ctx.Err()
@@ -191,8 +318,8 @@ func (d *Download) Start() (err error) {
 
 	// Otherwise there are always at least 2 chunks
 
-	file, err := os.Create(d.Path())
-	if err != nil {
+	file, err := d.createDownloadFile(d.TotalSize())
+	if err != nil || file == nil {
 		return err
 	}
 	defer file.Close()
@@ -215,6 +342,11 @@ func (d *Download) Start() (err error) {
 
 // RunProgress runs ProgressFunc based on Interval and updates lastSize.
 func (d *Download) RunProgress(fn ProgressFunc) {
+	d.runProgress(fn, nil)
+}
+
+// runProgress is RunProgress stopped by StopProgress or by closing stop.
+func (d *Download) runProgress(fn ProgressFunc, stop <-chan struct{}) {
 
 	// Set default interval.
 	if d.Interval == 0 {
@@ -229,10 +361,12 @@ func (d *Download) RunProgress(fn ProgressFunc) {
 			break
 		}
 
-		// Context check.
+		// Context and stop check.
 		select {
 		case <-d.ctx.Done():
 			return
+		case <-stop:
+			return
 		default:
 		}
 
@@ -252,9 +386,10 @@ func (d *Download) Context() context.Context {
 	return d.ctx
 }
 
-// TotalSize returns file total size (0 if unknown).
+// TotalSize returns file total size (0 if unknown),
+// the size of HLS and DASH downloads is estimated from the downloaded segments.
 func (d *Download) TotalSize() uint64 {
-	return d.info.Size
+	return atomic.LoadUint64(&d.info.Size)
 }
 
 // Size returns downloaded size.
@@ -294,6 +429,24 @@ func (d *Download) IsRangeable() bool {
 	return d.info.Rangeable
 }
 
+// FinalURL returns the URL after following redirects.
+func (d *Download) FinalURL() string {
+
+	d.urlMu.Lock()
+	defer d.urlMu.Unlock()
+
+	if d.finalURL != "" {
+		return d.finalURL
+	}
+
+	return d.URL
+}
+
+// Redirects returns the redirect chain followed by the probe request.
+func (d *Download) Redirects() []string {
+	return d.info.Redirects
+}
+
 // Download chunks
 func (d *Download) dl(dest io.WriterAt, errC chan error) {
 
@@ -312,31 +465,43 @@ func (d *Download) dl(dest io.WriterAt, errC chan error) {
 
 		go func(i int) {
 			defer wg.Done()
-
-			// Concurrently download and write chunk
-			if err := d.DownloadChunk(d.chunks[i], &OffsetWriter{dest, int64(d.chunks[i].Start)}); err != nil {
-				errC <- err
-				return
+			defer func() { <-max }()
+
+			// Concurrently download and write chunk, only the first error is reported.
+			if err := d.downloadChunk(d.chunks[i], dest); err != nil {
+				select {
+				case errC <- err:
+				default:
+				}
 			}
-
-			<-max
 		}(i)
 	}
 
 	wg.Wait()
-	errC <- nil
+
+	select {
+	case errC <- nil:
+	default:
+	}
 }
 
-// Return constant path which will not change once the download starts
+// Return constant path which will not change once the download starts,
+// unless ConflictRename renames the file.
 func (d *Download) Path() string {
 
+	d.pathMu.Lock()
+	defer d.pathMu.Unlock()
+
 	// Set the default path
 	if d.path == "" {
 
-		d.path = GetFilename(d.URL) // default case
+		d.path = GetFilename(d.FinalURL()) // default case
+		if d.path == DefaultFileName {
+			d.path = GetFilename(d.URL)
+		}
 		if d.Dest != "" {
 			d.path = d.Dest
-		} else if d.unsafeName != "" {
+		} else if d.useServerName() {
 			if path := getNameFromHeader(d.unsafeName); path != "" {
 				d.path = path
 			}
@@ -347,41 +512,58 @@ func (d *Download) Path() string {
 	return d.path
 }
 
-// DownloadChunk downloads a file chunk.
-func (d *Download) DownloadChunk(c *Chunk, dest io.Writer) error {
+// setPath sets the download path.
+func (d *Download) setPath(path string) {
 
-	var (
-		err error
-		req *http.Request
-		res *http.Response
-	)
+	d.pathMu.Lock()
+	d.path = path
+	d.pathMu.Unlock()
+}
 
-	if req, err = NewRequest(d.ctx, "GET", d.URL, d.Header); err != nil {
-		return err
+// useServerName reports whether the Content-Disposition name is used, based on ServerName.
+func (d *Download) useServerName() bool {
+
+	switch {
+	case d.unsafeName == "", d.ServerName == ServerNameIgnore:
+		return false
+	case d.ServerName == ServerNameFallback:
+		return d.path == DefaultFileName
 	}
 
-	contentRange := fmt.Sprintf("bytes=%d-%d", c.Start, c.End)
-	req.Header.Set("Range", contentRange)
+	return true
+}
+
+// DownloadChunk downloads a file chunk.
+func (d *Download) DownloadChunk(c *Chunk, dest io.Writer) error {
 
-	if res, err = d.Client.Do(req); err != nil {
+	p, err := getProtocol(d.URL)
+	if err != nil {
 		return err
 	}
 
-	// Verify the length
-	if res.ContentLength != int64(c.End-c.Start+1) {
-		return fmt.Errorf(
-			"Range request returned invalid Content-Length: %d however the range was: %s",
-			res.ContentLength, contentRange,
-		)
+	r, err := p.Open(d, c)
+	if err != nil {
+		return err
 	}
+	defer r.Close()
 
-	defer res.Body.Close()
-
-	_, err = io.CopyN(dest, io.TeeReader(res.Body, d), res.ContentLength)
+	_, err = io.CopyN(dest, io.TeeReader(r, d), int64(c.End-c.Start+1))
 
 	return err
 }
 
+// chunkIndex returns the index of c in the download chunks, or -1 if not found.
+func (d *Download) chunkIndex(c *Chunk) int {
+
+	for i := range d.chunks {
+		if d.chunks[i] == c {
+			return i
+		}
+	}
+
+	return -1
+}
+
 // NewDownload returns new *Download with context.
 func NewDownload(ctx context.Context, URL, dest string) *Download {
 	return &Download{
@@ -2,10 +2,20 @@ package got_test
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/melbahja/got"
@@ -47,6 +57,446 @@ func TestDownloading(t *testing.T) {
 	t.Run("coverTests", coverTests)
 }
 
+func TestRedirects(t *testing.T) {
+
+	var redirects uint64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		switch r.URL.Path {
+		case "/download":
+			atomic.AddUint64(&redirects, 1)
+			http.Redirect(w, r, "/signed", http.StatusFound)
+		case "/signed":
+			http.Redirect(w, r, "/files/go.mod?expires=1", http.StatusFound)
+		case "/files/go.mod":
+			http.ServeFile(w, r, "go.mod")
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("finalURLTest", func(t *testing.T) {
+
+		tmpDir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tmpDir)
+
+		d := &got.Download{
+			URL:       srv.URL + "/download",
+			Dir:       tmpDir,
+			ChunkSize: 10,
+		}
+
+		if err := d.Init(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := d.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := atomic.LoadUint64(&redirects); n != 1 {
+			t.Errorf("Expecting redirect to be followed once, but followed %d times", n)
+		}
+
+		if d.FinalURL() != srv.URL+"/files/go.mod?expires=1" {
+			t.Errorf("Invalid final URL: %s", d.FinalURL())
+		}
+
+		expected := []string{srv.URL + "/signed", srv.URL + "/files/go.mod?expires=1"}
+		if fmt.Sprint(d.Redirects()) != fmt.Sprint(expected) {
+			t.Errorf("Expecting redirects %v, but got %v", expected, d.Redirects())
+		}
+
+		if d.Path() != filepath.Join(tmpDir, "go.mod") {
+			t.Errorf("Expecting path %s, but got %s", filepath.Join(tmpDir, "go.mod"), d.Path())
+		}
+	})
+
+	t.Run("maxRedirectsTest", func(t *testing.T) {
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		d := &got.Download{URL: srv.URL + "/loop", Dest: tmpFile}
+
+		if err := d.Init(); !errors.Is(err, got.ErrTooManyRedirects) {
+			t.Errorf("Expecting too many redirects error, but got %v", err)
+		}
+
+		d = &got.Download{URL: srv.URL + "/download", Dest: tmpFile, MaxRedirects: 1}
+
+		if err := d.Init(); !errors.Is(err, got.ErrTooManyRedirects) {
+			t.Errorf("Expecting too many redirects error, but got %v", err)
+		}
+
+		// Redirects are disabled, the redirect response status fails.
+		d = &got.Download{URL: srv.URL + "/download", Dest: tmpFile, MaxRedirects: -1}
+
+		if err := d.Init(); err == nil || errors.Is(err, got.ErrTooManyRedirects) || !strings.Contains(err.Error(), "302") {
+			t.Errorf("Expecting the redirect status error, but got %v", err)
+		}
+
+		if len(d.Redirects()) != 0 {
+			t.Errorf("Expecting no followed redirects, but got %v", d.Redirects())
+		}
+	})
+
+	t.Run("downgradeTest", func(t *testing.T) {
+
+		tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, srv.URL+"/files/go.mod", http.StatusFound)
+		}))
+		defer tlsSrv.Close()
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		d := &got.Download{URL: tlsSrv.URL, Dest: tmpFile, Client: tlsSrv.Client()}
+
+		if err := d.Init(); !errors.Is(err, got.ErrRedirectDowngrade) {
+			t.Errorf("Expecting redirect downgrade error, but got %v", err)
+		}
+
+		d = &got.Download{URL: tlsSrv.URL, Dest: tmpFile, Client: tlsSrv.Client(), AllowDowngrade: true}
+
+		if err := d.Init(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestURLProvider(t *testing.T) {
+
+	var (
+		token = "1"
+		etag  = `"v1"`
+		mu    sync.Mutex
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.URL.Query().Get("token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		http.ServeFile(w, r, "go.mod")
+	}))
+	defer srv.Close()
+
+	// expire sets the valid token and etag then returns a URL provider of the new token.
+	expire := func(newToken, newEtag string, calls *uint64) got.URLProvider {
+
+		mu.Lock()
+		token, etag = newToken, newEtag
+		mu.Unlock()
+
+		return func(ctx context.Context) (string, error) {
+			atomic.AddUint64(calls, 1)
+			return srv.URL + "/file?token=" + newToken, nil
+		}
+	}
+
+	t.Run("refreshTest", func(t *testing.T) {
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		d := &got.Download{
+			URL:       srv.URL + "/file?token=1",
+			Dest:      tmpFile,
+			ChunkSize: 10,
+		}
+
+		if err := d.Init(); err != nil {
+			t.Fatal(err)
+		}
+
+		var calls uint64
+		d.URLProvider = expire("2", `"v1"`, &calls)
+
+		if err := d.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := atomic.LoadUint64(&calls); n != 1 {
+			t.Errorf("Expecting URL provider to be called once, but called %d times", n)
+		}
+
+		mod, _ := ioutil.ReadFile("go.mod")
+		data, _ := ioutil.ReadFile(tmpFile)
+
+		if string(mod) != string(data) {
+			t.Error("Corrupted file")
+		}
+	})
+
+	t.Run("mismatchTest", func(t *testing.T) {
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		d := &got.Download{
+			URL:       srv.URL + "/file?token=2",
+			Dest:      tmpFile,
+			ChunkSize: 10,
+		}
+
+		if err := d.Init(); err != nil {
+			t.Fatal(err)
+		}
+
+		var calls uint64
+		d.URLProvider = expire("3", `"v2"`, &calls)
+
+		if err := d.Start(); !errors.Is(err, got.ErrURLMismatch) {
+			t.Errorf("Expecting URL mismatch error, but got %v", err)
+		}
+	})
+
+	t.Run("seeOtherTest", func(t *testing.T) {
+
+		var expired int32
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			switch {
+			case r.URL.Path == "/post" && r.Method == http.MethodPost && atomic.LoadInt32(&expired) == 0:
+				http.ServeFile(w, r, "go.mod")
+			case r.URL.Path == "/post":
+				w.WriteHeader(http.StatusForbidden)
+			case r.URL.Path == "/see-other":
+				http.Redirect(w, r, "/get", http.StatusSeeOther)
+			case r.URL.Path == "/get" && r.Method == http.MethodGet:
+				http.ServeFile(w, r, "go.mod")
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}))
+		defer srv.Close()
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		d := &got.Download{
+			URL:       srv.URL + "/post",
+			Method:    http.MethodPost,
+			Dest:      tmpFile,
+			ChunkSize: 10,
+		}
+
+		if err := d.Init(); err != nil {
+			t.Fatal(err)
+		}
+
+		// The refreshed URL is a 303 redirect, the chunks are requested with GET.
+		atomic.StoreInt32(&expired, 1)
+		d.URLProvider = func(ctx context.Context) (string, error) {
+			return srv.URL + "/see-other", nil
+		}
+
+		if err := d.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		if d.FinalURL() != srv.URL+"/get" {
+			t.Errorf("Expecting final URL %s/get, but got %s", srv.URL, d.FinalURL())
+		}
+
+		mod, _ := ioutil.ReadFile("go.mod")
+		data, _ := ioutil.ReadFile(tmpFile)
+
+		if string(mod) != string(data) {
+			t.Error("Corrupted file")
+		}
+	})
+}
+
+func TestRequestHook(t *testing.T) {
+
+	sign := func(r *http.Request) string {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(r.Header.Get("Range")))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("X-Signature") != sign(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		http.ServeFile(w, r, "go.mod")
+	}))
+	defer srv.Close()
+
+	tmpFile := createTemp()
+	defer clean(tmpFile)
+
+	var (
+		mu     sync.Mutex
+		kinds  = make(map[got.RequestType]int)
+		chunks = make(map[int]bool)
+	)
+
+	g := got.New()
+	g.RequestHook = func(req *http.Request, kind got.RequestKind) error {
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		kinds[kind.Type]++
+
+		if kind.Type == got.ChunkRequest {
+
+			if req.Header.Get("Range") != fmt.Sprintf("bytes=%d-%d", kind.Chunk.Start, kind.Chunk.End) {
+				return fmt.Errorf("Invalid chunk %d range: %s", kind.Index, req.Header.Get("Range"))
+			}
+
+			chunks[kind.Index] = true
+		}
+
+		req.Header.Set("X-Signature", sign(req))
+		return nil
+	}
+
+	if err := g.Do(&got.Download{URL: srv.URL, Dest: tmpFile, ChunkSize: 50}); err != nil {
+		t.Fatal(err)
+	}
+
+	if kinds[got.ProbeRequest] != 1 {
+		t.Errorf("Expecting 1 probe request, but got %d", kinds[got.ProbeRequest])
+	}
+
+	if kinds[got.ChunkRequest] == 0 || kinds[got.ChunkRequest] != len(chunks) {
+		t.Errorf("Expecting %d chunk requests with distinct indexes, but got %d", kinds[got.ChunkRequest], len(chunks))
+	}
+
+	// Hook errors abort the download.
+	g.RequestHook = func(req *http.Request, kind got.RequestKind) error {
+		return errors.New("hook error")
+	}
+
+	if err := g.Do(&got.Download{URL: srv.URL, Dest: tmpFile}); err == nil || err.Error() != "hook error" {
+		t.Errorf("Expecting hook error, but got %v", err)
+	}
+}
+
+func TestRequestHeader(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		switch {
+		case fmt.Sprint(r.Header["Accept"]) != "[text/plain application/json]":
+			http.Error(w, "invalid Accept", http.StatusBadRequest)
+		case fmt.Sprint(r.Header["X-Test"]) != "[a b]":
+			http.Error(w, "invalid X-Test", http.StatusBadRequest)
+		case r.Host != "example.test":
+			http.Error(w, "invalid Host", http.StatusBadRequest)
+		case len(r.Header["User-Agent"]) != 0:
+			http.Error(w, "invalid User-Agent", http.StatusBadRequest)
+		case r.Header.Get("X-Empty") != "" || len(r.Header["X-Empty"]) != 1:
+			http.Error(w, "invalid X-Empty", http.StatusBadRequest)
+		default:
+			http.ServeFile(w, r, "go.mod")
+		}
+	}))
+	defer srv.Close()
+
+	tmpFile := createTemp()
+	defer clean(tmpFile)
+
+	d := &got.Download{
+		URL:       srv.URL,
+		Dest:      tmpFile,
+		ChunkSize: 50,
+		Header: []got.GotHeader{
+			{Key: "x-test", Value: "a"},
+			{Key: "X-Test", Value: "b"},
+		},
+		RequestHeader: http.Header{
+			"Accept":     {"text/plain", "application/json"},
+			"Host":       {"example.test"},
+			"User-Agent": nil,
+			"X-Empty":    {""},
+		},
+	}
+
+	if err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequestBody(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch r.URL.Path {
+		case "/export":
+			if r.Method != http.MethodPost || string(body) != `{"id":1}` {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+				return
+			}
+			http.ServeFile(w, r, "go.mod")
+		case "/export_redirect":
+			http.Redirect(w, r, "/files/go.mod", http.StatusSeeOther)
+		case "/files/go.mod":
+			if r.Method != http.MethodGet || len(body) != 0 {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+				return
+			}
+			http.ServeFile(w, r, "go.mod")
+		}
+	}))
+	defer srv.Close()
+
+	tests := []*got.Download{
+		{URL: srv.URL + "/export", Method: http.MethodPost, Body: []byte(`{"id":1}`)},
+		{URL: srv.URL + "/export", Method: http.MethodPost, GetBody: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(`{"id":1}`)), nil
+		}},
+		{URL: srv.URL + "/export_redirect", Method: http.MethodPost, Body: []byte(`{"id":1}`)},
+	}
+
+	for _, d := range tests {
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		d.Dest = tmpFile
+		d.ChunkSize = 50
+
+		if err := d.Init(); err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if err := d.Start(); err != nil {
+			t.Error(err)
+		}
+
+		if !d.IsRangeable() {
+			t.Error("rangeable should be true")
+		}
+	}
+}
+
 func getInfoTest(t *testing.T) {
 
 	tmpFile := createTemp()
@@ -101,13 +551,16 @@ func sendHeadersTest(t *testing.T) {
 
 func getFilenameTest(t *testing.T) {
 
-	tmpDir := os.TempDir()
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
 	defer os.RemoveAll(tmpDir)
 
 	dl := got.NewDownload(context.Background(), httpt.URL+"/file_name", "")
 	dl.Dir = tmpDir
 
-	_, err := dl.GetInfoOrDownload()
+	_, err = dl.GetInfoOrDownload()
 
 	if err != nil {
 
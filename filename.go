@@ -1,34 +1,250 @@
 package got
 
 import (
-	"mime"
+	"fmt"
 	"net/url"
 	"path/filepath"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // DefaultFileName is the fallback name for GetFilename.
 var DefaultFileName = "got.output"
 
+// maxNameLength is the maximum file name length in bytes.
+const maxNameLength = 255
+
+// ServerNamePolicy is how the Content-Disposition file name is used when Dest is not set.
+type ServerNamePolicy int
+
+const (
+
+	// ServerNamePrefer uses the server name over the URL name, the default.
+	ServerNamePrefer ServerNamePolicy = iota
+
+	// ServerNameFallback uses the server name only when the URL has no file name.
+	ServerNameFallback
+
+	// ServerNameIgnore always uses the URL name.
+	ServerNameIgnore
+)
+
+// serverNamePolicies are the policy names.
+var serverNamePolicies = []string{"prefer", "fallback", "ignore"}
+
+func (p ServerNamePolicy) String() string {
+
+	if p < 0 || int(p) >= len(serverNamePolicies) {
+		return fmt.Sprintf("ServerNamePolicy(%d)", p)
+	}
+
+	return serverNamePolicies[p]
+}
+
+// ParseServerNamePolicy returns the policy of a name: prefer, fallback or ignore.
+func ParseServerNamePolicy(name string) (ServerNamePolicy, error) {
+
+	for i, n := range serverNamePolicies {
+		if n == name {
+			return ServerNamePolicy(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown server name policy %s, expecting one of: %s", name, strings.Join(serverNamePolicies, ", "))
+}
+
 // GetFilename it returns default file name from a URL.
 func GetFilename(URL string) string {
 
 	if u, err := url.Parse(URL); err == nil && filepath.Ext(u.Path) != "" {
 
-		return filepath.Base(u.Path)
+		if name := sanitizeFilename(u.Path); name != "" {
+			return name
+		}
 	}
 
 	return DefaultFileName
 }
 
+// getNameFromHeader returns the sanitized file name of a Content-Disposition header value,
+// the RFC 5987 filename* parameter is preferred over the filename parameter.
 func getNameFromHeader(val string) string {
 
-	_, params, err := mime.ParseMediaType(val)
+	params := dispositionParams(val)
+
+	if name, ok := decodeExtValue(params["filename*"]); ok {
+		if name = sanitizeFilename(name); name != "" {
+			return name
+		}
+	}
+
+	return sanitizeFilename(params["filename"])
+}
+
+// dispositionParams returns the lower case parameters of a Content-Disposition value,
+// it accepts the unquoted values with spaces sent by some servers.
+func dispositionParams(val string) map[string]string {
+
+	params := make(map[string]string)
+
+	// Skip the disposition type.
+	_, rest, _ := strings.Cut(val, ";")
+
+	for rest != "" {
+
+		var key, value string
+
+		key, rest, _ = strings.Cut(rest, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		if rest = strings.TrimLeft(rest, " \t"); strings.HasPrefix(rest, `"`) {
+			value, rest = quotedString(rest)
+			_, rest, _ = strings.Cut(rest, ";")
+		} else {
+			value, rest, _ = strings.Cut(rest, ";")
+			value = strings.TrimSpace(value)
+		}
+
+		// The first value of a parameter is used.
+		if _, ok := params[key]; !ok && key != "" {
+			params[key] = value
+		}
+	}
+
+	return params
+}
+
+// quotedString returns the unescaped value of the quoted string s and the rest after it.
+func quotedString(s string) (string, string) {
+
+	var b strings.Builder
+
+	for i := 1; i < len(s); i++ {
+
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	// Unterminated quoted string.
+	return b.String(), ""
+}
+
+// decodeExtValue decodes an RFC 5987 charset'language'percent-encoded value,
+// the UTF-8, ISO-8859-1 and US-ASCII charsets are supported.
+func decodeExtValue(val string) (string, bool) {
+
+	parts := strings.SplitN(val, "'", 3)
+	if len(parts) != 3 {
+		return "", false
+	}
+
+	value, err := url.PathUnescape(parts[2])
+	if err != nil {
+		return "", false
+	}
+
+	switch strings.ToLower(parts[0]) {
+	case "utf-8":
+		return value, utf8.ValidString(value)
+	case "iso-8859-1":
+
+		runes := make([]rune, len(value))
+		for i := 0; i < len(value); i++ {
+			runes[i] = rune(value[i])
+		}
+
+		return string(runes), true
+	case "us-ascii":
+
+		for i := 0; i < len(value); i++ {
+			if value[i] >= utf8.RuneSelf {
+				return "", false
+			}
+		}
+
+		return value, true
+	}
+
+	return "", false
+}
+
+// sanitizeFilename returns a file name safe on all the platforms: the directories, control and
+// bidi characters are removed, the reserved characters are replaced, the leading and trailing
+// dots and spaces are trimmed, the reserved device names are prefixed, and long names are
+// shortened keeping the extension. It returns an empty string when nothing is left.
+func sanitizeFilename(name string) string {
+
+	// Keep the last path element of both separators.
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	name = strings.Map(func(r rune) rune {
+
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r), unicode.Is(unicode.Bidi_Control, r):
+			return -1
+		case strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		}
+
+		return r
+	}, strings.ToValidUTF8(name, ""))
 
-	// Prevent path traversal
-	if err != nil || strings.Contains(params["filename"], "..") || strings.Contains(params["filename"], "/") || strings.Contains(params["filename"], "\\") {
+	name = strings.Trim(name, " .")
+
+	if name == "" {
 		return ""
 	}
 
-	return params["filename"]
+	if isReservedName(name) {
+		name = "_" + name
+	}
+
+	return truncateName(name, maxNameLength)
+}
+
+// isReservedName reports whether the name is a Windows device name, with or without extension.
+func isReservedName(name string) bool {
+
+	base, _, _ := strings.Cut(name, ".")
+	base = strings.ToUpper(strings.TrimSpace(base))
+
+	switch base {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	}
+
+	return len(base) == 4 && (strings.HasPrefix(base, "COM") || strings.HasPrefix(base, "LPT")) && base[3] >= '1' && base[3] <= '9'
+}
+
+// truncateName shortens the name to max bytes on a rune boundary, keeping short extensions.
+func truncateName(name string, max int) string {
+
+	if len(name) <= max {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	if len(ext) > 16 {
+		ext = ""
+	}
+
+	base := name[:max-len(ext)]
+
+	for !utf8.ValidString(base) {
+		base = base[:len(base)-1]
+	}
+
+	return strings.TrimRight(base, " .") + ext
 }
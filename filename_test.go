@@ -1,6 +1,7 @@
 package got
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -13,14 +14,54 @@ var TestUrls = map[string]string{
 	"http://example.com/some/path/":                                          "got.output",
 	"http://example.com/some/path/?page=about":                               "got.output",
 	"http://example.com/about.php?session=asdf":                              "about.php",
+	"http://example.com/a%3Cb%3E.txt":                                        "a_b_.txt",
+	"http://example.com/new%0Aline.txt":                                      "newline.txt",
+	"http://example.com/a%2F..%2F..%2Fpasswd.txt":                            "passwd.txt",
+	"http://example.com/con.txt":                                             "_con.txt",
 }
 
 var TestHeaderValues = map[string]string{
 	`attachment`:                                 "",
 	`attachment; filename="filename.jpg"`:        "filename.jpg",
-	`attachment; filename="../filename.jpg"`:     "",
-	`attachment; filename="../../../etc/passwd"`: "",
+	`attachment; filename="../filename.jpg"`:     "filename.jpg",
+	`attachment; filename="../../../etc/passwd"`: "passwd",
 	`attachment; name="test"; filename="go.mp4"`: "go.mp4",
+
+	// RFC 5987 extended values.
+	`attachment; filename*=UTF-8''na%C3%AFve%20file.txt`:                     "naïve file.txt",
+	`attachment; filename*=utf-8'en'%E2%82%AC%20rates.pdf`:                   "€ rates.pdf",
+	`attachment; filename*=ISO-8859-1''caf%E9.txt`:                           "café.txt",
+	`attachment; filename*=US-ASCII''plain.txt`:                              "plain.txt",
+	`attachment; filename="fallback.txt"; filename*=UTF-8''pr%C3%A9f.txt`:    "préf.txt",
+	`attachment; filename*=UTF-8''pr%C3%A9f.txt; filename="fallback.txt"`:    "préf.txt",
+	`attachment; filename="fallback.txt"; filename*=KOI8-R''%C6%C1%CA%CC`:    "fallback.txt",
+	`attachment; filename="fallback.txt"; filename*=UTF-8''%FF%FE.txt`:       "fallback.txt",
+	`attachment; filename="fallback.txt"; filename*=UTF-8''bad%ZZ.txt`:       "fallback.txt",
+	`attachment; filename="fallback.txt"; filename*=no-quotes.txt`:           "fallback.txt",
+	`attachment; filename*=UTF-8''..%2F..%2Fetc%2Fpasswd`:                    "passwd",
+	`attachment; filename*=UTF-8''%2E%2E`:                                    "",
+	`attachment; FileName="Case.txt"`:                                        "Case.txt",
+	`attachment; filename=unquoted name.txt`:                                 "unquoted name.txt",
+	`attachment; filename="with \"quotes\".txt"`:                             "with _quotes_.txt",
+	`attachment; filename="semi;colon.txt"; size=10`:                         "semi;colon.txt",
+	`attachment; filename="unterminated.txt`:                                 "unterminated.txt",
+	`attachment; filename=""`:                                                "",
+	`attachment; filename="windows\\path\\file.txt"`:                         "file.txt",
+	"inline; filename=\"tab\there.txt\"":                                     "tabhere.txt",
+	`attachment; filename="NUL"`:                                             "_NUL",
+	`attachment; filename*=UTF-8''%E2%80%AEtxt.exe%0D%0A`:                    "txt.exe",
+	`attachment; filename="  .hidden. "`:                                     "hidden",
+	`attachment; filename="a:b?.txt"`:                                        "a_b_.txt",
+	`attachment; filename*=UTF-8''%F0%9F%93%84%20report%00.pdf`:              "📄 report.pdf",
+	`attachment; filename*=UTF-8''com1.tar.gz`:                               "_com1.tar.gz",
+	`attachment; filename="lpt10.txt"`:                                       "lpt10.txt",
+	`attachment; filename="file.txt"; filename="other.txt"`:                  "file.txt",
+	`attachment; filename*=UTF-8''; filename="empty-ext.txt"`:                "empty-ext.txt",
+	`attachment; filename*=iso-8859-1'de'%DCbersicht%20%A7%201.txt`:          "Übersicht § 1.txt",
+	`attachment; filename*="UTF-8''quoted%20ext.txt"`:                        "quoted ext.txt",
+	`attachment;filename="nospace.txt"`:                                      "nospace.txt",
+	`attachment; filename = "spaced.txt"`:                                    "spaced.txt",
+	`attachment; filename*=UTF-8''%C3%A9t%C3%A9.txt; filename*=UTF-8''x.txt`: "été.txt",
 }
 
 func TestGetFilename(t *testing.T) {
@@ -34,7 +75,97 @@ func TestGetFilename(t *testing.T) {
 func TestGetDefaultFileNameFromHeader(t *testing.T) {
 	for url, expected := range TestHeaderValues {
 		if result := getNameFromHeader(url); result != expected {
-			t.Errorf("Expected name '%s' from url '%s', but got '%s'", expected, url, result)
+			t.Errorf("Expected name '%s' from header '%s', but got '%s'", expected, url, result)
+		}
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+
+	long := strings.Repeat("a", 300)
+	longUnicode := strings.Repeat("é", 200)
+
+	tests := []struct {
+		name, expected string
+	}{
+		{"file.txt", "file.txt"},
+		{"", ""},
+		{".", ""},
+		{"..", ""},
+		{"...", ""},
+		{"dir/", ""},
+		{"/etc/passwd", "passwd"},
+		{`C:\Windows\system.ini`, "system.ini"},
+		{"bell\a.txt", "bell.txt"},
+		{"del\x7f.txt", "del.txt"},
+		{"c1\u0085.txt", "c1.txt"},
+		{"invalid\xff\xfe.txt", "invalid.txt"},
+		{`<>:"|?*.txt`, "_______.txt"},
+		{"trailing. . ", "trailing"},
+		{"CON", "_CON"},
+		{"con.txt", "_con.txt"},
+		{"Aux.tar.gz", "_Aux.tar.gz"},
+		{"COM9", "_COM9"},
+		{"COM0", "COM0"},
+		{"console.txt", "console.txt"},
+		{"nul .txt", "_nul .txt"},
+		{long + ".txt", long[:251] + ".txt"},
+		{long, long[:255]},
+		{long + "." + long, long[:255]},
+		{longUnicode + ".txt", strings.Repeat("é", 125) + ".txt"},
+	}
+
+	for _, test := range tests {
+
+		result := sanitizeFilename(test.name)
+
+		if result != test.expected {
+			t.Errorf("Expected sanitized name %q of %q, but got %q", test.expected, test.name, result)
+		}
+
+		if len(result) > maxNameLength {
+			t.Errorf("Expected name of at most %d bytes, got %d", maxNameLength, len(result))
+		}
+	}
+}
+
+func TestServerNamePolicy(t *testing.T) {
+
+	const header = `attachment; filename="server.bin"`
+
+	tests := []struct {
+		URL, dest, header string
+		policy            ServerNamePolicy
+		expected          string
+	}{
+		{"http://example.com/url.bin", "", header, ServerNamePrefer, "server.bin"},
+		{"http://example.com/", "", header, ServerNamePrefer, "server.bin"},
+		{"http://example.com/url.bin", "", "", ServerNamePrefer, "url.bin"},
+		{"http://example.com/url.bin", "", `attachment; filename=".."`, ServerNamePrefer, "url.bin"},
+		{"http://example.com/url.bin", "", header, ServerNameFallback, "url.bin"},
+		{"http://example.com/", "", header, ServerNameFallback, "server.bin"},
+		{"http://example.com/url.bin", "", header, ServerNameIgnore, "url.bin"},
+		{"http://example.com/", "", header, ServerNameIgnore, DefaultFileName},
+		{"http://example.com/url.bin", "dest.bin", header, ServerNamePrefer, "dest.bin"},
+	}
+
+	for _, test := range tests {
+
+		d := &Download{URL: test.URL, Dest: test.dest, ServerName: test.policy, unsafeName: test.header}
+
+		if path := d.Path(); path != test.expected {
+			t.Errorf("Expected %s path %s of %s, but got %s", test.policy, test.expected, test.URL, path)
 		}
 	}
+
+	for _, p := range []ServerNamePolicy{ServerNamePrefer, ServerNameFallback, ServerNameIgnore} {
+
+		if parsed, err := ParseServerNamePolicy(p.String()); err != nil || parsed != p {
+			t.Errorf("Expected policy %s, but got %v, %v", p, parsed, err)
+		}
+	}
+
+	if _, err := ParseServerNamePolicy("always"); err == nil {
+		t.Error("Expected unknown policy error")
+	}
 }
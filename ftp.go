@@ -0,0 +1,468 @@
+package got
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ftpProtocol downloads ftp and ftps URLs, the file size is read with SIZE and
+// chunks are downloaded concurrently using REST over separate connections.
+//
+// ftps URLs use implicit TLS on the default port 990, and explicit TLS (AUTH TLS)
+// when another port is set in the URL.
+type ftpProtocol struct{}
+
+// ftpConn is an FTP control connection.
+type ftpConn struct {
+	ctx context.Context
+
+	conn net.Conn
+
+	text *textproto.Conn
+
+	// tls is set when the data connections must use TLS.
+	tls *tls.Config
+
+	noEPSV bool
+
+	done chan struct{}
+
+	closeOnce sync.Once
+}
+
+// ErrFTPLineBreak is returned when an FTP command argument contains CR or LF.
+var ErrFTPLineBreak = errors.New("FTP argument contains a line break")
+
+// ftpSessions caches the TLS sessions, so data connections can resume the control connection session.
+var ftpSessions = tls.NewLRUClientSessionCache(64)
+
+// Probe gets the file size and REST support state, when not supported
+// the whole file is retrieved.
+func (ftpProtocol) Probe(d *Download) (*Info, io.ReadCloser, error) {
+
+	c, path, err := dialFTP(d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info := &Info{URL: d.URL}
+
+	size, sizeErr := c.size(path)
+	if sizeErr == nil {
+		info.Size = size
+		_, _, err = c.cmd(3, "REST 0")
+		info.Rangeable = err == nil && size > 0
+	}
+
+	if info.Rangeable {
+		c.Close()
+		return info, nil, nil
+	}
+
+	r, err := c.retr(path, 0)
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	return info, r, nil
+}
+
+// Open retrieves the file starting from the chunk start over a new connection.
+func (ftpProtocol) Open(d *Download, chunk *Chunk) (io.ReadCloser, error) {
+
+	c, path, err := dialFTP(d)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := c.retr(path, chunk.Start)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return &struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(r, int64(chunk.End-chunk.Start+1)), r}, nil
+}
+
+// dialFTP connects and logs in to the download URL server, it returns the connection and the file path.
+func dialFTP(d *Download) (*ftpConn, string, error) {
+
+	u, err := url.Parse(d.URL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var (
+		secure   = u.Scheme == "ftps"
+		implicit = secure && (u.Port() == "" || u.Port() == "990")
+		port     = u.Port()
+		config   *tls.Config
+		dialer   = &net.Dialer{Timeout: 30 * time.Second}
+	)
+
+	if port == "" {
+		port = "21"
+		if secure {
+			port = "990"
+		}
+	}
+
+	if secure {
+
+		if d.TLS != nil {
+			if config, err = d.TLS.Config(); err != nil {
+				return nil, "", err
+			}
+		} else {
+			config = &tls.Config{}
+		}
+
+		config.ServerName = u.Hostname()
+		config.ClientSessionCache = ftpSessions
+	}
+
+	conn, err := dialer.DialContext(d.ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if implicit {
+		conn = tls.Client(conn, config)
+	}
+
+	c := &ftpConn{
+		ctx:  d.ctx,
+		conn: conn,
+		text: textproto.NewConn(conn),
+		done: make(chan struct{}),
+	}
+
+	// Close the connection when the context is done.
+	go func() {
+		select {
+		case <-d.ctx.Done():
+			c.Close()
+		case <-c.done:
+		}
+	}()
+
+	if err = c.login(u, d, config, implicit); err != nil {
+		c.Close()
+
+		// Prefer the context error when the connection is closed by the context.
+		if d.ctx.Err() != nil {
+			return nil, "", d.ctx.Err()
+		}
+
+		return nil, "", err
+	}
+
+	// The path is relative to the login directory, "//" is used for absolute paths.
+	path := strings.TrimPrefix(u.Path, "/")
+
+	if err = ftpArg(path); err != nil {
+		c.Close()
+		return nil, "", err
+	}
+
+	return c, path, nil
+}
+
+func (c *ftpConn) login(u *url.URL, d *Download, config *tls.Config, implicit bool) (err error) {
+
+	if _, _, err = c.read(2); err != nil {
+		return err
+	}
+
+	if config != nil && !implicit {
+
+		if _, _, err = c.cmd(2, "AUTH TLS"); err != nil {
+			return err
+		}
+
+		// c.conn is kept as is, it's closed by the context goroutine.
+		c.text = textproto.NewConn(tls.Client(c.conn, config))
+	}
+
+	if config != nil {
+
+		if _, _, err = c.cmd(2, "PBSZ 0"); err != nil {
+			return err
+		}
+
+		if _, _, err = c.cmd(2, "PROT P"); err != nil {
+			return err
+		}
+
+		c.tls = config
+	}
+
+	user, pass, err := ftpCredentials(u, d)
+	if err != nil {
+		return err
+	}
+
+	if err = ftpArg(user); err != nil {
+		return err
+	}
+
+	if err = ftpArg(pass); err != nil {
+		return err
+	}
+
+	code, _, err := c.cmd(0, "USER %s", user)
+	if err != nil {
+		return err
+	}
+
+	switch code {
+	case 230:
+	case 331, 332:
+		if _, _, err = c.cmd(2, "PASS %s", pass); err != nil {
+			return err
+		}
+	default:
+		return &textproto.Error{Code: code, Msg: "Unexpected USER response"}
+	}
+
+	_, _, err = c.cmd(2, "TYPE I")
+
+	return err
+}
+
+// ftpCredentials returns the URL credentials, or the download Auth credentials,
+// the anonymous user is used if none.
+func ftpCredentials(u *url.URL, d *Download) (string, string, error) {
+
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		return u.User.Username(), pass, nil
+	}
+
+	if d.Auth != nil {
+
+		if d.Auth.Username != "" {
+			return d.Auth.Username, d.Auth.Password, nil
+		}
+
+		if d.Auth.Netrc {
+
+			machine, err := lookupNetrc(d.Auth.NetrcFile, u.Hostname())
+			if err != nil {
+				return "", "", err
+			}
+
+			if machine != nil && machine.login != "" {
+				return machine.login, machine.password, nil
+			}
+		}
+	}
+
+	return "anonymous", "anonymous@", nil
+}
+
+func (c *ftpConn) size(path string) (uint64, error) {
+
+	_, msg, err := c.cmd(2, "SIZE %s", path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(msg), 10, 64)
+}
+
+// retr opens a passive data connection and retrieves path starting from offset,
+// closing the returned reader closes the connection.
+func (c *ftpConn) retr(path string, offset uint64) (io.ReadCloser, error) {
+
+	data, err := c.passive()
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 {
+		if _, _, err = c.cmd(3, "REST %d", offset); err != nil {
+			data.Close()
+			return nil, err
+		}
+	}
+
+	if _, _, err = c.cmd(1, "RETR %s", path); err != nil {
+		data.Close()
+		return nil, err
+	}
+
+	return &ftpReader{data: data, conn: c}, nil
+}
+
+// passive opens a data connection using extended passive mode, or passive mode
+// when EPSV is not supported.
+func (c *ftpConn) passive() (net.Conn, error) {
+
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if err != nil {
+		return nil, err
+	}
+
+	var port int
+
+	if !c.noEPSV {
+
+		_, msg, err := c.cmd(2, "EPSV")
+
+		if err == nil {
+			port, err = parseEPSV(msg)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			c.noEPSV = true
+		}
+	}
+
+	if port == 0 {
+
+		_, msg, err := c.cmd(2, "PASV")
+		if err != nil {
+			return nil, err
+		}
+
+		// The address from the reply is ignored, it's often wrong behind NAT.
+		if port, err = parsePASV(msg); err != nil {
+			return nil, err
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+
+	conn, err := dialer.DialContext(c.ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	if c.tls != nil {
+		conn = tls.Client(conn, c.tls)
+	}
+
+	return conn, nil
+}
+
+// ftpArg returns ErrFTPLineBreak when the argument would inject commands.
+func ftpArg(arg string) error {
+
+	if strings.ContainsAny(arg, "\r\n") {
+		return ErrFTPLineBreak
+	}
+
+	return nil
+}
+
+// cmd sends a command and reads its response, expectCode is checked like textproto.Reader.ReadResponse.
+func (c *ftpConn) cmd(expectCode int, format string, args ...interface{}) (int, string, error) {
+
+	if _, err := c.text.Cmd(format, args...); err != nil {
+		return 0, "", err
+	}
+
+	return c.read(expectCode)
+}
+
+func (c *ftpConn) read(expectCode int) (int, string, error) {
+	return c.text.ReadResponse(expectCode)
+}
+
+// Close closes the control connection.
+func (c *ftpConn) Close() error {
+
+	var err error
+
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.conn.Close()
+	})
+
+	return err
+}
+
+// ftpReader reads a data connection, it checks the transfer completion reply at EOF.
+type ftpReader struct {
+	data net.Conn
+
+	conn *ftpConn
+}
+
+func (r *ftpReader) Read(b []byte) (int, error) {
+
+	n, err := r.data.Read(b)
+
+	if err == io.EOF {
+		if _, _, rerr := r.conn.read(2); rerr != nil {
+			return n, rerr
+		}
+	}
+
+	return n, err
+}
+
+func (r *ftpReader) Close() error {
+	r.data.Close()
+	return r.conn.Close()
+}
+
+// parseEPSV returns the port of an EPSV reply: "Entering Extended Passive Mode (|||6446|)".
+func parseEPSV(msg string) (int, error) {
+
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start == -1 || end < start {
+		return 0, fmt.Errorf("Invalid EPSV response: %s", msg)
+	}
+
+	fields := strings.Split(msg[start+1:end], "|")
+	if len(fields) != 5 {
+		return 0, fmt.Errorf("Invalid EPSV response: %s", msg)
+	}
+
+	return strconv.Atoi(fields[3])
+}
+
+// parsePASV returns the port of a PASV reply: "Entering Passive Mode (h1,h2,h3,h4,p1,p2)".
+func parsePASV(msg string) (int, error) {
+
+	start := strings.IndexAny(msg, "0123456789")
+	if start == -1 {
+		return 0, errors.New("Invalid PASV response: " + msg)
+	}
+
+	end := start
+	for end < len(msg) && strings.IndexByte("0123456789,", msg[end]) != -1 {
+		end++
+	}
+
+	fields := strings.Split(msg[start:end], ",")
+	if len(fields) != 6 {
+		return 0, errors.New("Invalid PASV response: " + msg)
+	}
+
+	p1, err1 := strconv.Atoi(fields[4])
+	p2, err2 := strconv.Atoi(fields[5])
+
+	if err1 != nil || err2 != nil {
+		return 0, errors.New("Invalid PASV response: " + msg)
+	}
+
+	return p1<<8 | p2, nil
+}
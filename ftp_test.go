@@ -0,0 +1,369 @@
+package got_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/melbahja/got"
+)
+
+func TestFTP(t *testing.T) {
+
+	content, err := os.ReadFile("go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("epsvTest", func(t *testing.T) {
+
+		srv := newFTPServer(t, content)
+		defer srv.Close()
+
+		downloadFTP(t, srv.URL()+"/go.mod", nil, content)
+
+		if n := atomic.LoadUint64(&srv.retrs); n < 2 {
+			t.Errorf("Expecting chunked RETR requests, but got %d", n)
+		}
+	})
+
+	t.Run("pasvTest", func(t *testing.T) {
+
+		srv := newFTPServer(t, content)
+		srv.noEPSV = true
+		defer srv.Close()
+
+		downloadFTP(t, srv.URL()+"/go.mod", nil, content)
+	})
+
+	t.Run("noRestTest", func(t *testing.T) {
+
+		srv := newFTPServer(t, content)
+		srv.noREST = true
+		defer srv.Close()
+
+		downloadFTP(t, srv.URL()+"/go.mod", nil, content)
+
+		if n := atomic.LoadUint64(&srv.retrs); n != 1 {
+			t.Errorf("Expecting 1 RETR request, but got %d", n)
+		}
+	})
+
+	t.Run("credentialsTest", func(t *testing.T) {
+
+		srv := newFTPServer(t, content)
+		srv.user, srv.pass = "user", "secret"
+		defer srv.Close()
+
+		downloadFTP(t, "ftp://user:secret@"+srv.ln.Addr().String()+"/go.mod", nil, content)
+
+		d := &got.Download{URL: srv.URL() + "/go.mod", Auth: &got.Auth{Username: "user", Password: "secret"}}
+		downloadFTP(t, d.URL, d, content)
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		if err := got.New().Do(&got.Download{URL: srv.URL() + "/go.mod", Dest: tmpFile}); err == nil {
+			t.Error("Expecting login error for anonymous user")
+		}
+	})
+
+	t.Run("notFoundTest", func(t *testing.T) {
+
+		srv := newFTPServer(t, content)
+		defer srv.Close()
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		if err := got.New().Do(&got.Download{URL: srv.URL() + "/404", Dest: tmpFile}); err == nil {
+			t.Error("Expecting not found error")
+		}
+	})
+
+	t.Run("lineBreakTest", func(t *testing.T) {
+
+		srv := newFTPServer(t, content)
+		defer srv.Close()
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		for _, URL := range []string{
+			srv.URL() + "/go.mod%0D%0ADELE%20go.mod",
+			"ftp://user%0D%0ADELE%20go.mod:secret@" + srv.ln.Addr().String() + "/go.mod",
+			"ftp://user:secret%0A@" + srv.ln.Addr().String() + "/go.mod",
+		} {
+			if err := got.New().Do(&got.Download{URL: URL, Dest: tmpFile}); !errors.Is(err, got.ErrFTPLineBreak) {
+				t.Errorf("Expecting ErrFTPLineBreak of %s, but got %v", URL, err)
+			}
+		}
+	})
+
+	t.Run("ftpsTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		createClientCert(t, certFile, keyFile)
+
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		srv := newFTPServer(t, content)
+		srv.tls = &tls.Config{Certificates: []tls.Certificate{cert}}
+		defer srv.Close()
+
+		URL := "ftps://" + srv.ln.Addr().String() + "/go.mod"
+
+		downloadFTP(t, URL, &got.Download{TLS: &got.TLSConfig{Insecure: true}}, content)
+
+		if atomic.LoadUint64(&srv.secure) == 0 {
+			t.Error("Expecting TLS data connections")
+		}
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		if err := got.New().Do(&got.Download{URL: URL, Dest: tmpFile}); err == nil {
+			t.Error("Expecting TLS verification error")
+		}
+	})
+}
+
+func downloadFTP(t *testing.T, URL string, d *got.Download, expect []byte) {
+
+	tmpFile := createTemp()
+	defer clean(tmpFile)
+
+	if d == nil {
+		d = &got.Download{}
+	}
+
+	d.URL, d.Dest, d.ChunkSize = URL, tmpFile, 100
+
+	if err := got.New().Do(d); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, expect) {
+		t.Errorf("Expecting downloaded file to match, but got %d bytes", len(data))
+	}
+}
+
+// ftpServer is a minimal FTP server serving go.mod.
+type ftpServer struct {
+	ln net.Listener
+
+	content []byte
+
+	user, pass string
+
+	noEPSV, noREST bool
+
+	tls *tls.Config
+
+	retrs, secure uint64
+}
+
+func newFTPServer(t *testing.T, content []byte) *ftpServer {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &ftpServer{ln: ln, content: content, user: "anonymous"}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *ftpServer) URL() string {
+	return "ftp://" + s.ln.Addr().String()
+}
+
+func (s *ftpServer) Close() {
+	s.ln.Close()
+}
+
+func (s *ftpServer) serve(conn net.Conn) {
+
+	defer conn.Close()
+
+	var (
+		text    = textproto.NewConn(conn)
+		offset  int
+		user    string
+		logged  bool
+		private bool
+		data    net.Listener
+	)
+
+	defer func() {
+		if data != nil {
+			data.Close()
+		}
+	}()
+
+	text.PrintfLine("220 ready")
+
+	for {
+
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i != -1 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+
+		if !logged && cmd != "USER" && cmd != "PASS" && cmd != "AUTH" && cmd != "PBSZ" && cmd != "PROT" {
+			text.PrintfLine("530 Not logged in")
+			continue
+		}
+
+		switch cmd {
+
+		case "AUTH":
+
+			if s.tls == nil {
+				text.PrintfLine("502 Not implemented")
+				continue
+			}
+
+			text.PrintfLine("234 Proceed")
+			conn = tls.Server(conn, s.tls)
+			text = textproto.NewConn(conn)
+
+		case "PBSZ":
+			text.PrintfLine("200 OK")
+
+		case "PROT":
+			private = arg == "P"
+			text.PrintfLine("200 OK")
+
+		case "USER":
+			user = arg
+			text.PrintfLine("331 Password required")
+
+		case "PASS":
+
+			if user != s.user || (s.pass != "" && arg != s.pass) {
+				text.PrintfLine("530 Login incorrect")
+				continue
+			}
+
+			logged = true
+			text.PrintfLine("230 Logged in")
+
+		case "TYPE":
+			text.PrintfLine("200 OK")
+
+		case "SIZE":
+
+			if arg != "go.mod" {
+				text.PrintfLine("550 Not found")
+				continue
+			}
+
+			text.PrintfLine("213 %d", len(s.content))
+
+		case "REST":
+
+			if s.noREST {
+				text.PrintfLine("502 Not implemented")
+				continue
+			}
+
+			offset, _ = strconv.Atoi(arg)
+			text.PrintfLine("350 Restarting at %d", offset)
+
+		case "EPSV", "PASV":
+
+			if cmd == "EPSV" && s.noEPSV {
+				text.PrintfLine("502 Not implemented")
+				continue
+			}
+
+			if data, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				text.PrintfLine("425 Cannot open data connection")
+				continue
+			}
+
+			port := data.Addr().(*net.TCPAddr).Port
+
+			if cmd == "EPSV" {
+				text.PrintfLine("229 Entering Extended Passive Mode (|||%d|)", port)
+			} else {
+				text.PrintfLine("227 Entering Passive Mode (10,0,0,1,%d,%d)", port>>8, port&0xff)
+			}
+
+		case "RETR":
+
+			if arg != "go.mod" || data == nil {
+				text.PrintfLine("550 Not found")
+				continue
+			}
+
+			atomic.AddUint64(&s.retrs, 1)
+			text.PrintfLine("150 Opening data connection")
+
+			dc, err := data.Accept()
+			data.Close()
+			data = nil
+
+			if err != nil {
+				return
+			}
+
+			if private {
+				dc = tls.Server(dc, s.tls)
+				atomic.AddUint64(&s.secure, 1)
+			}
+
+			_, err = dc.Write(s.content[offset:])
+			dc.Close()
+			offset = 0
+
+			if err != nil {
+				text.PrintfLine("426 Transfer aborted")
+				continue
+			}
+
+			text.PrintfLine("226 Transfer complete")
+
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+
+		default:
+			text.PrintfLine("502 %s not implemented", cmd)
+		}
+	}
+}
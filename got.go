@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"net/textproto"
 	"time"
 )
 
@@ -13,6 +14,24 @@ type Got struct {
 
 	Client *http.Client
 
+	// Auth is the default authentication of got downloads.
+	Auth *Auth
+
+	// Jar is the default cookie jar of got downloads.
+	Jar http.CookieJar
+
+	// TLS is the default TLS options of got downloads.
+	TLS *TLSConfig
+
+	// S3 is the default S3 options of got downloads.
+	S3 *S3Config
+
+	// RequestHook is the default request hook of got downloads.
+	RequestHook RequestHook
+
+	// Logger is the default logger of got downloads.
+	Logger Logger
+
 	ctx context.Context
 }
 
@@ -22,6 +41,21 @@ var UserAgent = "Got/1.0"
 // ErrDownloadAborted - When download is aborted by the OS before it is completed, ErrDownloadAborted will be triggered
 var ErrDownloadAborted = errors.New("Operation aborted")
 
+// DefaultMaxRedirects is the default maximum number of redirects to follow.
+var DefaultMaxRedirects = 10
+
+// ErrTooManyRedirects is returned when the redirects exceed the download MaxRedirects.
+var ErrTooManyRedirects = errors.New("Too many redirects")
+
+// ErrRedirectDowngrade is returned when redirected from https to http and the download doesn't allow it.
+var ErrRedirectDowngrade = errors.New("Redirect from https to http is not allowed")
+
+// ErrURLMismatch is returned when a refreshed URL doesn't match the download size or ETag.
+var ErrURLMismatch = errors.New("Refreshed URL does not match the download")
+
+// maxURLRefreshes is the maximum number of URL refreshes for a single chunk.
+const maxURLRefreshes = 3
+
 // DefaultClient is the default http client for got requests.
 var DefaultClient = &http.Client{
 	Transport: &http.Transport{
@@ -46,22 +80,59 @@ func (g Got) Download(URL, dest string) error {
 // Do inits and runs ProgressFunc if set and starts the Download.
 func (g Got) Do(dl *Download) error {
 
+	g.setDefaults(dl)
+
 	if err := dl.Init(); err != nil {
 		return err
 	}
 
 	if g.ProgressFunc != nil {
 
-		defer func() {
-			dl.StopProgress = true
-		}()
+		stop := make(chan struct{})
+		defer close(stop)
 
-		go dl.RunProgress(g.ProgressFunc)
+		go dl.runProgress(g.ProgressFunc, stop)
 	}
 
 	return dl.Start()
 }
 
+// setDefaults uses got defaults for the unset download options.
+func (g Got) setDefaults(dl *Download) {
+
+	if dl.ctx == nil {
+		dl.ctx = g.ctx
+	}
+
+	if dl.Client == nil {
+		dl.Client = g.Client
+	}
+
+	if dl.Auth == nil {
+		dl.Auth = g.Auth
+	}
+
+	if dl.Jar == nil {
+		dl.Jar = g.Jar
+	}
+
+	if dl.TLS == nil {
+		dl.TLS = g.TLS
+	}
+
+	if dl.S3 == nil {
+		dl.S3 = g.S3
+	}
+
+	if dl.RequestHook == nil {
+		dl.RequestHook = g.RequestHook
+	}
+
+	if dl.Logger == nil {
+		dl.Logger = g.Logger
+	}
+}
+
 // New returns new *Got with default context and client.
 func New() *Got {
 	return NewWithContext(context.Background())
@@ -75,7 +146,8 @@ func NewWithContext(ctx context.Context) *Got {
 	}
 }
 
-// NewRequest returns a new http.Request and error if any.
+// NewRequest returns a new http.Request and error if any,
+// header values are added, the first value of a key replaces the default value.
 func NewRequest(ctx context.Context, method, URL string, header []GotHeader) (req *http.Request, err error) {
 
 	if req, err = http.NewRequestWithContext(ctx, method, URL, nil); err != nil {
@@ -84,9 +156,48 @@ func NewRequest(ctx context.Context, method, URL string, header []GotHeader) (re
 
 	req.Header.Set("User-Agent", UserAgent)
 
+	set := make(map[string]bool)
+
 	for _, h := range header {
-		req.Header.Set(h.Key, h.Value)
+
+		key := textproto.CanonicalMIMEHeaderKey(h.Key)
+
+		if key == "Host" {
+			req.Host = h.Value
+			continue
+		}
+
+		if !set[key] {
+			set[key] = true
+			req.Header.Del(key)
+		}
+
+		req.Header.Add(key, h.Value)
 	}
 
 	return
 }
+
+// setHeader sets the header keys of req, a key without values removes the header.
+func setHeader(req *http.Request, header http.Header) {
+
+	for key, values := range header {
+
+		key = textproto.CanonicalMIMEHeaderKey(key)
+
+		if key == "Host" {
+			if len(values) > 0 {
+				req.Host = values[0]
+			}
+			continue
+		}
+
+		if len(values) == 0 {
+			// Keep the key, so net/http doesn't send its default value.
+			req.Header[key] = nil
+			continue
+		}
+
+		req.Header[key] = append([]string(nil), values...)
+	}
+}
@@ -0,0 +1,485 @@
+package got
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// HLSConfig selects the variant and decryption key of HLS playlist downloads.
+type HLSConfig struct {
+
+	// Bandwidth selects the variant with the highest bandwidth not above it,
+	// defaults to the highest bandwidth variant.
+	Bandwidth uint64
+
+	// Resolution selects the variants with the resolution, e.g. "1280x720".
+	Resolution string
+
+	// Key is the AES-128 key used instead of the playlist key URIs.
+	Key []byte
+}
+
+// ErrNoVariant is returned when no playlist variant matches the HLS options.
+var ErrNoVariant = errors.New("No variant matches the HLS options")
+
+type (
+	hlsVariant struct {
+		URI        string
+		Bandwidth  uint64
+		Resolution string
+	}
+
+	hlsKey struct {
+		Method, URI string
+
+		// IV is the key IV, the media sequence number is used if nil.
+		IV []byte
+	}
+
+	hlsSegment struct {
+		URI string
+
+		Range *Chunk
+
+		Key *hlsKey
+
+		Sequence uint64
+	}
+
+	hlsPlaylist struct {
+		Variants []hlsVariant
+
+		// Segments of a media playlist, including the EXT-X-MAP init sections.
+		Segments []*hlsSegment
+
+		// fMP4 is set when the playlist has an init section.
+		fMP4 bool
+	}
+)
+
+// initHLS gets the media playlist of the download URL, and sets the download segments and path.
+func (d *Download) initHLS() error {
+
+	data, base, err := d.getManifest(d.URL)
+	if err != nil {
+		return err
+	}
+
+	playlist, err := parseHLS(data)
+	if err != nil {
+		return err
+	}
+
+	if len(playlist.Variants) > 0 {
+
+		variant, err := selectVariant(playlist.Variants, d.HLS)
+		if err != nil {
+			return err
+		}
+
+		URL, err := resolveURL(base, variant.URI)
+		if err != nil {
+			return err
+		}
+
+		if data, base, err = d.getManifest(URL); err != nil {
+			return err
+		}
+
+		if playlist, err = parseHLS(data); err != nil {
+			return err
+		}
+	}
+
+	if len(playlist.Segments) == 0 {
+		return fmt.Errorf("HLS playlist has no segments: %s", base)
+	}
+
+	keys := &hlsKeys{d: d, base: base, keys: make(map[string][]byte)}
+
+	for _, s := range playlist.Segments {
+
+		URL, err := resolveURL(base, s.URI)
+		if err != nil {
+			return err
+		}
+
+		seg := &segment{URL: URL, Range: s.Range}
+
+		if s.Key != nil {
+
+			if s.Key.Method != "AES-128" {
+				return fmt.Errorf("Unsupported HLS encryption method: %s", s.Key.Method)
+			}
+
+			seg.decrypt = keys.decrypter(s)
+		}
+
+		d.segments = append(d.segments, seg)
+	}
+
+	d.info = &Info{URL: base.String()}
+
+	// Name the file after the media playlist.
+	if d.Dest == "" && d.path == "" {
+
+		ext := ".ts"
+		if playlist.fMP4 {
+			ext = ".mp4"
+		}
+
+		name := GetFilename(base.String())
+		d.path = filepath.Join(d.Dir, strings.TrimSuffix(name, filepath.Ext(name))+ext)
+	}
+
+	return nil
+}
+
+// startHLS downloads the playlist segments and concatenates them into the download file.
+func (d *Download) startHLS() error {
+
+	file, err := d.createDownloadFile(0)
+	if err != nil || file == nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+
+	err = d.downloadSegments(d.segments, func(_ int, data []byte) error {
+		_, err := w.Write(data)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// selectVariant returns the variant matching the HLS options.
+func selectVariant(variants []hlsVariant, c *HLSConfig) (*hlsVariant, error) {
+
+	var selected, lowest *hlsVariant
+
+	for i := range variants {
+
+		v := &variants[i]
+
+		if c.Resolution != "" && !strings.EqualFold(v.Resolution, c.Resolution) {
+			continue
+		}
+
+		if lowest == nil || v.Bandwidth < lowest.Bandwidth {
+			lowest = v
+		}
+
+		if c.Bandwidth > 0 && v.Bandwidth > c.Bandwidth {
+			continue
+		}
+
+		if selected == nil || v.Bandwidth > selected.Bandwidth {
+			selected = v
+		}
+	}
+
+	// Use the lowest bandwidth when all variants are above the bandwidth.
+	if selected == nil {
+		selected = lowest
+	}
+
+	if selected == nil {
+		return nil, fmt.Errorf("%w: resolution %s", ErrNoVariant, c.Resolution)
+	}
+
+	return selected, nil
+}
+
+// parseHLS parses a master or media playlist.
+func parseHLS(data []byte) (*hlsPlaylist, error) {
+
+	var (
+		playlist  = &hlsPlaylist{}
+		scanner   = bufio.NewScanner(bytes.NewReader(data))
+		sequence  uint64
+		key       *hlsKey
+		mapURI    string
+		variant   *hlsVariant
+		byteRange string
+		lastEnd   = make(map[string]uint64)
+		header    bool
+	)
+
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" {
+			continue
+		}
+
+		if !header {
+			if line != "#EXTM3U" {
+				return nil, errors.New("Invalid HLS playlist: missing #EXTM3U")
+			}
+			header = true
+			continue
+		}
+
+		tag, value := line, ""
+		if i := strings.IndexByte(line, ':'); i != -1 {
+			tag, value = line[:i], line[i+1:]
+		}
+
+		switch tag {
+
+		case "#EXT-X-STREAM-INF":
+
+			attrs := parseAttributes(value)
+			bandwidth, _ := strconv.ParseUint(attrs["BANDWIDTH"], 10, 64)
+			variant = &hlsVariant{Bandwidth: bandwidth, Resolution: attrs["RESOLUTION"]}
+
+		case "#EXT-X-MEDIA-SEQUENCE":
+
+			sequence, _ = strconv.ParseUint(value, 10, 64)
+
+		case "#EXT-X-KEY":
+
+			attrs := parseAttributes(value)
+
+			if attrs["METHOD"] == "NONE" {
+				key = nil
+				continue
+			}
+
+			key = &hlsKey{Method: attrs["METHOD"], URI: attrs["URI"]}
+
+			if iv := attrs["IV"]; iv != "" {
+
+				b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X"))
+				if err != nil || len(b) != aes.BlockSize {
+					return nil, fmt.Errorf("Invalid HLS key IV: %s", iv)
+				}
+
+				key.IV = b
+			}
+
+		case "#EXT-X-MAP":
+
+			attrs := parseAttributes(value)
+
+			if attrs["URI"] == "" || attrs["URI"]+attrs["BYTERANGE"] == mapURI {
+				continue
+			}
+
+			mapURI = attrs["URI"] + attrs["BYTERANGE"]
+			playlist.fMP4 = true
+
+			s := &hlsSegment{URI: attrs["URI"], Key: key, Sequence: sequence}
+
+			if attrs["BYTERANGE"] != "" {
+
+				r, err := parseByteRange(attrs["BYTERANGE"], 0)
+				if err != nil {
+					return nil, err
+				}
+
+				s.Range = r
+			}
+
+			playlist.Segments = append(playlist.Segments, s)
+
+		case "#EXT-X-BYTERANGE":
+
+			// The range is parsed with the segment URI.
+			byteRange = value
+
+		default:
+
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			if variant != nil {
+				variant.URI = line
+				playlist.Variants = append(playlist.Variants, *variant)
+				variant = nil
+				continue
+			}
+
+			s := &hlsSegment{URI: line, Key: key, Sequence: sequence}
+
+			if byteRange != "" {
+
+				// Without offset, the range starts at the end of the previous range of the same URI.
+				r, err := parseByteRange(byteRange, lastEnd[line])
+				if err != nil {
+					return nil, err
+				}
+
+				lastEnd[line] = r.End + 1
+				s.Range = r
+				byteRange = ""
+			}
+
+			playlist.Segments = append(playlist.Segments, s)
+			sequence++
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !header {
+		return nil, errors.New("Invalid HLS playlist: missing #EXTM3U")
+	}
+
+	return playlist, nil
+}
+
+// parseByteRange parses a "<length>[@<offset>]" range, start is used when the offset is omitted.
+func parseByteRange(value string, start uint64) (*Chunk, error) {
+
+	length, offset, ok := strings.Cut(value, "@")
+
+	n, err := strconv.ParseUint(length, 10, 64)
+	if err != nil || n == 0 {
+		return nil, fmt.Errorf("Invalid HLS byte range: %s", value)
+	}
+
+	if ok {
+		if start, err = strconv.ParseUint(offset, 10, 64); err != nil {
+			return nil, fmt.Errorf("Invalid HLS byte range: %s", value)
+		}
+	}
+
+	return &Chunk{Start: start, End: start + n - 1}, nil
+}
+
+// parseAttributes parses an HLS attribute list, quoted values are unquoted.
+func parseAttributes(s string) map[string]string {
+
+	attrs := make(map[string]string)
+
+	for s != "" {
+
+		i := strings.IndexByte(s, '=')
+		if i == -1 {
+			break
+		}
+
+		name, rest := strings.TrimSpace(s[:i]), s[i+1:]
+		value := rest
+
+		if strings.HasPrefix(rest, `"`) {
+
+			end := strings.IndexByte(rest[1:], '"')
+			if end == -1 {
+				value, s = rest[1:], ""
+			} else {
+				value, s = rest[1:end+1], rest[end+2:]
+			}
+
+		} else if j := strings.IndexByte(rest, ','); j != -1 {
+			value, s = rest[:j], rest[j:]
+		} else {
+			s = ""
+		}
+
+		attrs[strings.ToUpper(name)] = value
+		s = strings.TrimPrefix(strings.TrimSpace(s), ",")
+	}
+
+	return attrs
+}
+
+// hlsKeys downloads and caches the playlist keys.
+type hlsKeys struct {
+	d *Download
+
+	base *url.URL
+
+	mu sync.Mutex
+
+	keys map[string][]byte
+}
+
+func (k *hlsKeys) get(URI string) ([]byte, error) {
+
+	if k.d.HLS.Key != nil {
+		return k.d.HLS.Key, nil
+	}
+
+	URL, err := resolveURL(k.base, URI)
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if key, ok := k.keys[URL]; ok {
+		return key, nil
+	}
+
+	key, _, err := k.d.getManifest(URL)
+	if err != nil {
+		return nil, fmt.Errorf("HLS key: %w", err)
+	}
+
+	k.keys[URL] = key
+
+	return key, nil
+}
+
+// decrypter returns the AES-128 CBC decrypt function of the segment.
+func (k *hlsKeys) decrypter(s *hlsSegment) func([]byte) ([]byte, error) {
+
+	return func(data []byte) ([]byte, error) {
+
+		key, err := k.get(s.Key.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid HLS key: %w", err)
+		}
+
+		if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+			return nil, fmt.Errorf("Invalid encrypted segment size: %d", len(data))
+		}
+
+		iv := s.Key.IV
+		if iv == nil {
+			iv = make([]byte, aes.BlockSize)
+			binary.BigEndian.PutUint64(iv[8:], s.Sequence)
+		}
+
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+
+		// Remove the PKCS7 padding.
+		pad := int(data[len(data)-1])
+		if pad == 0 || pad > aes.BlockSize {
+			return nil, errors.New("Invalid segment padding, wrong HLS key?")
+		}
+
+		return data[:len(data)-pad], nil
+	}
+}
@@ -0,0 +1,211 @@
+package got_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/melbahja/got"
+)
+
+func TestHLS(t *testing.T) {
+
+	content, err := os.ReadFile("go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := []byte("0123456789abcdef")
+	parts := splitBytes(content, 5)
+
+	var media strings.Builder
+
+	media.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-MEDIA-SEQUENCE:7\n")
+	media.WriteString("#EXT-X-KEY:METHOD=AES-128,URI=\"/key\"\n")
+
+	files := map[string][]byte{"/key": key, "/hd/key": key}
+
+	for i, part := range parts {
+
+		// The last segments are not encrypted, and the third one uses an explicit IV.
+		switch i {
+		case 2:
+			media.WriteString("#EXT-X-KEY:METHOD=AES-128,URI=\"key\",IV=0x000102030405060708090a0b0c0d0e0f\n")
+			part = encryptSegment(t, key, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, part)
+		case 3:
+			media.WriteString("#EXT-X-KEY:METHOD=NONE\n")
+		case 0, 1:
+			iv := make([]byte, 16)
+			binary.BigEndian.PutUint64(iv[8:], uint64(7+i))
+			part = encryptSegment(t, key, iv, part)
+		}
+
+		name := fmt.Sprintf("seg%d.ts", i)
+		files["/hd/"+name] = part
+		fmt.Fprintf(&media, "#EXTINF:4.0,\n%s\n", name)
+	}
+
+	media.WriteString("#EXT-X-ENDLIST\n")
+
+	files["/hd/index.m3u8"] = []byte(media.String())
+	files["/sd/index.m3u8"] = []byte("#EXTM3U\n#EXTINF:4.0,\nmissing.ts\n#EXT-X-ENDLIST\n")
+	files["/master.m3u8"] = []byte(`#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2"
+sd/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2800000,RESOLUTION=1280x720,CODECS="avc1.4d401f,mp4a.40.2"
+hd/index.m3u8
+`)
+
+	files["/range/all.ts"] = content
+	files["/range/index.m3u8"] = []byte(fmt.Sprintf(`#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MAP:URI="all.ts",BYTERANGE="10@0"
+#EXTINF:4.0,
+#EXT-X-BYTERANGE:100@10
+all.ts
+#EXTINF:4.0,
+#EXT-X-BYTERANGE:%d
+all.ts
+#EXT-X-ENDLIST
+`, len(content)-110))
+
+	var hideKeys int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		data, ok := files[r.URL.Path]
+		if !ok || (strings.HasSuffix(r.URL.Path, "key") && atomic.LoadInt32(&hideKeys) == 1) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		http.ServeContent(w, r, "", time.Now(), bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	t.Run("masterTest", func(t *testing.T) {
+		downloadHLS(t, srv.URL+"/master.m3u8", &got.HLSConfig{}, content)
+		downloadHLS(t, srv.URL+"/master.m3u8", &got.HLSConfig{Bandwidth: 5000000, Resolution: "1280x720"}, content)
+	})
+
+	t.Run("mediaTest", func(t *testing.T) {
+		downloadHLS(t, srv.URL+"/hd/index.m3u8", &got.HLSConfig{}, content)
+	})
+
+	t.Run("keyTest", func(t *testing.T) {
+
+		atomic.StoreInt32(&hideKeys, 1)
+		defer atomic.StoreInt32(&hideKeys, 0)
+
+		downloadHLS(t, srv.URL+"/hd/index.m3u8", &got.HLSConfig{Key: key}, content)
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		if err := got.New().Do(&got.Download{URL: srv.URL + "/hd/index.m3u8", Dest: tmpFile, HLS: &got.HLSConfig{Key: []byte("invalid key 1234")}}); err == nil {
+			t.Error("Expecting decryption error with invalid key")
+		}
+	})
+
+	t.Run("bandwidthTest", func(t *testing.T) {
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		// Only the sd variant is below the bandwidth.
+		if err := got.New().Do(&got.Download{URL: srv.URL + "/master.m3u8", Dest: tmpFile, HLS: &got.HLSConfig{Bandwidth: 1000000}}); err == nil {
+			t.Error("Expecting sd variant segment error")
+		}
+	})
+
+	t.Run("resolutionTest", func(t *testing.T) {
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		err := got.New().Do(&got.Download{URL: srv.URL + "/master.m3u8", Dest: tmpFile, HLS: &got.HLSConfig{Resolution: "1920x1080"}})
+
+		if !errors.Is(err, got.ErrNoVariant) {
+			t.Errorf("Expecting ErrNoVariant, but got %v", err)
+		}
+	})
+
+	t.Run("byteRangeTest", func(t *testing.T) {
+		downloadHLS(t, srv.URL+"/range/index.m3u8", &got.HLSConfig{}, content)
+	})
+
+	t.Run("defaultNameTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		d := &got.Download{URL: srv.URL + "/range/index.m3u8", Dir: dir, HLS: &got.HLSConfig{}}
+
+		if err := got.New().Do(d); err != nil {
+			t.Fatal(err)
+		}
+
+		if expect := filepath.Join(dir, "index.mp4"); d.Path() != expect {
+			t.Errorf("Expecting path %s, but got %s", expect, d.Path())
+		}
+
+		if d.Size() != d.TotalSize() || d.Size() != uint64(len(content)) {
+			t.Errorf("Expecting size %d, but got %d of %d", len(content), d.Size(), d.TotalSize())
+		}
+	})
+}
+
+func downloadHLS(t *testing.T, URL string, config *got.HLSConfig, expect []byte) {
+
+	tmpFile := createTemp()
+	defer clean(tmpFile)
+
+	if err := got.New().Do(&got.Download{URL: URL, Dest: tmpFile, HLS: config, Concurrency: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, expect) {
+		t.Errorf("Expecting downloaded file to match, but got:\n%s", data)
+	}
+}
+
+func splitBytes(b []byte, n int) (parts [][]byte) {
+
+	size := len(b) / n
+
+	for i := 0; i < n-1; i++ {
+		parts = append(parts, b[i*size:(i+1)*size])
+	}
+
+	return append(parts, b[(n-1)*size:])
+}
+
+func encryptSegment(t *testing.T, key, iv, data []byte) []byte {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	out := append(append([]byte(nil), data...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, out)
+
+	return out
+}
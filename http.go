@@ -0,0 +1,463 @@
+package got
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+
+	// RequestType is the type of a download request.
+	RequestType uint8
+
+	// RequestKind describes the request passed to RequestHook.
+	RequestKind struct {
+		Type RequestType
+
+		// Chunk and its Index in the download chunks, set for chunk requests.
+		Chunk *Chunk
+		Index int
+	}
+
+	// RequestHook is called before sending each download request, it can sign or change the request.
+	RequestHook func(req *http.Request, kind RequestKind) error
+
+	// signFunc signs a request after RequestHook, it's used by protocols built on top of http.
+	signFunc func(req *http.Request) error
+
+	// httpProtocol downloads http and https URLs using range requests.
+	httpProtocol struct{}
+)
+
+const (
+
+	// ProbeRequest gets the file info, or the whole file if it's not rangeable.
+	ProbeRequest RequestType = iota
+
+	// ChunkRequest downloads a chunk range.
+	ChunkRequest
+
+	// RefreshRequest checks a URL returned by URLProvider.
+	RefreshRequest
+)
+
+// String returns the request type name.
+func (t RequestType) String() string {
+
+	switch t {
+	case ProbeRequest:
+		return "probe"
+	case ChunkRequest:
+		return "chunk"
+	case RefreshRequest:
+		return "refresh"
+	}
+
+	return "unknown"
+}
+
+// statusError is returned when the response status code is not ok.
+type statusError int
+
+func (code statusError) Error() string {
+	return fmt.Sprintf("Response status code is not ok: %d", int(code))
+}
+
+// Probe tries downloading the first byte of the file using a range request.
+// If the server supports range requests, then we'll extract the length info from content-range,
+// Otherwise the response body is returned to download the whole file in one go.
+func (httpProtocol) Probe(d *Download) (*Info, io.ReadCloser, error) {
+	return d.probeHTTP(d.URL, nil)
+}
+
+// Open requests the chunk range from the final URL.
+func (httpProtocol) Open(d *Download, c *Chunk) (io.ReadCloser, error) {
+	return d.openHTTP(c, nil)
+}
+
+// probeHTTP sends the probe request to URL, the request is signed with sign if set.
+func (d *Download) probeHTTP(URL string, sign signFunc) (*Info, io.ReadCloser, error) {
+
+	req, err := d.newRequest(d.method(), URL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("Range", "bytes=0-0")
+
+	if err = d.hook(req, RequestKind{Type: ProbeRequest}); err != nil {
+		return nil, nil, err
+	}
+
+	if sign != nil {
+		if err = sign(req); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	res, err := d.do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if res.StatusCode >= 300 {
+		res.Body.Close()
+		return nil, nil, statusError(res.StatusCode)
+	}
+
+	// Set the final URL, chunks are requested from it without following the redirects again.
+	info := &Info{
+		URL:       res.Request.URL.String(),
+		Redirects: redirectChain(res),
+		ETag:      res.Header.Get("ETag"),
+	}
+	d.setFinalURL(info.URL, res.Request.Method)
+
+	// Set content disposition non trusted name
+	d.unsafeName = res.Header.Get("content-disposition")
+
+	// Get content length from content-range response header,
+	// if content-range exists, that means partial content is supported.
+	if cr := res.Header.Get("content-range"); cr != "" && res.ContentLength == 1 {
+
+		res.Body.Close()
+
+		if length, ok := parseContentRange(cr); ok {
+
+			info.Size = length
+			info.Rangeable = true
+			return info, nil, nil
+		}
+
+		// Make sure the caller knows about the problem and we don't just silently fail
+		return nil, nil, fmt.Errorf("Response includes content-range header which is invalid: %s", cr)
+	}
+
+	return info, res.Body, nil
+}
+
+// openHTTP requests the chunk range from the final URL, the request is signed with sign if set.
+func (d *Download) openHTTP(c *Chunk, sign signFunc) (io.ReadCloser, error) {
+
+	// The method and URL are changed together by refreshURL.
+	d.urlMu.Lock()
+	method := d.finalMethod
+	d.urlMu.Unlock()
+
+	if method == "" {
+		method = d.method()
+	}
+
+	req, err := d.newRequest(method, d.FinalURL())
+	if err != nil {
+		return nil, err
+	}
+
+	// Credentials are not sent to the final URL when it's another origin.
+	if u, err := url.Parse(d.URL); err == nil && originOf(u) != originOf(req.URL) {
+		req.Header.Del("Authorization")
+		req.Header.Del("Cookie")
+	}
+
+	contentRange := fmt.Sprintf("bytes=%d-%d", c.Start, c.End)
+	req.Header.Set("Range", contentRange)
+
+	if err = d.hook(req, RequestKind{Type: ChunkRequest, Chunk: c, Index: d.chunkIndex(c)}); err != nil {
+		return nil, err
+	}
+
+	if sign != nil {
+		if err = sign(req); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := d.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 300 {
+		res.Body.Close()
+		return nil, statusError(res.StatusCode)
+	}
+
+	// Verify the length
+	if res.ContentLength != int64(c.End-c.Start+1) {
+		res.Body.Close()
+		return nil, fmt.Errorf(
+			"Range request returned invalid Content-Length: %d however the range was: %s",
+			res.ContentLength, contentRange,
+		)
+	}
+
+	return res.Body, nil
+}
+
+// downloadChunk downloads a chunk, and retries it with a refreshed URL
+// when the URL is expired and URLProvider is set.
+func (d *Download) downloadChunk(c *Chunk, dest io.WriterAt) error {
+
+	for refreshes := 0; ; refreshes++ {
+
+		d.urlMu.Lock()
+		gen := d.urlGen
+		d.urlMu.Unlock()
+
+		err := d.DownloadChunk(c, &OffsetWriter{dest, int64(c.Start)})
+
+		var status statusError
+		if d.URLProvider == nil || refreshes >= maxURLRefreshes || !errors.As(err, &status) ||
+			(status != http.StatusUnauthorized && status != http.StatusForbidden) {
+
+			if err != nil {
+				d.log(LogWarn, "chunk failed", "url", d.URL, "start", c.Start, "end", c.End, "error", err)
+			}
+
+			return err
+		}
+
+		d.log(LogWarn, "refreshing URL", "url", d.URL, "start", c.Start, "end", c.End, "status", int(status), "refreshes", refreshes+1)
+
+		if err = d.refreshURL(gen); err != nil {
+			return err
+		}
+	}
+}
+
+// refreshURL gets a new URL from URLProvider unless it's already refreshed since gen,
+// and checks that it still serves the same file.
+func (d *Download) refreshURL(gen uint) error {
+
+	d.urlMu.Lock()
+	defer d.urlMu.Unlock()
+
+	if d.urlGen != gen {
+		return nil
+	}
+
+	URL, err := d.URLProvider(d.ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := d.newRequest(d.method(), URL)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Range", "bytes=0-0")
+
+	if err = d.hook(req, RequestKind{Type: RefreshRequest}); err != nil {
+		return err
+	}
+
+	res, err := d.do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return statusError(res.StatusCode)
+	}
+
+	size, _ := parseContentRange(res.Header.Get("content-range"))
+
+	if size != d.info.Size || res.Header.Get("ETag") != d.info.ETag {
+		return fmt.Errorf("%w: size %d, ETag %s", ErrURLMismatch, size, res.Header.Get("ETag"))
+	}
+
+	d.finalURL = res.Request.URL.String()
+	d.finalMethod = res.Request.Method
+	d.urlGen++
+
+	return nil
+}
+
+func (d *Download) setFinalURL(URL, method string) {
+	d.urlMu.Lock()
+	d.finalURL, d.finalMethod = URL, method
+	d.urlMu.Unlock()
+}
+
+// parseContentRange returns the complete length from a content-range header value.
+func parseContentRange(cr string) (uint64, bool) {
+
+	if l := strings.Split(cr, "/"); len(l) == 2 {
+
+		if length, err := strconv.ParseUint(l[1], 10, 64); err == nil {
+			return length, true
+		}
+	}
+
+	return 0, false
+}
+
+// newRequest returns a new download request with the download headers,
+// the body is only sent with the download method.
+func (d *Download) newRequest(method, URL string) (*http.Request, error) {
+
+	req, err := NewRequest(d.ctx, method, URL, d.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	setHeader(req, d.RequestHeader)
+
+	if method != d.method() || (len(d.Body) == 0 && d.GetBody == nil) {
+		return req, nil
+	}
+
+	getBody := d.GetBody
+
+	if getBody == nil {
+
+		getBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(d.Body)), nil
+		}
+
+		req.ContentLength = int64(len(d.Body))
+	}
+
+	if req.Body, err = getBody(); err != nil {
+		return nil, err
+	}
+
+	req.GetBody = getBody
+
+	return req, nil
+}
+
+func (d *Download) method() string {
+
+	if d.Method == "" {
+		return http.MethodGet
+	}
+
+	return d.Method
+}
+
+// hook passes the request to RequestHook if set.
+func (d *Download) hook(req *http.Request, kind RequestKind) error {
+
+	if d.RequestHook == nil {
+		return nil
+	}
+
+	return d.RequestHook(req, kind)
+}
+
+// do sends the request using the download client.
+func (d *Download) do(req *http.Request) (*http.Response, error) {
+
+	d.clientOnce.Do(func() {
+		d.client, d.clientErr = d.newClient()
+	})
+
+	if d.clientErr != nil {
+		return nil, d.clientErr
+	}
+
+	start := time.Now()
+	res, err := d.client.Do(req)
+
+	if err != nil {
+		d.log(LogWarn, "request failed", "method", req.Method, "url", req.URL, "range", req.Header.Get("Range"), "duration", time.Since(start), "error", err)
+		return nil, err
+	}
+
+	d.log(LogDebug, "request", "method", req.Method, "url", req.URL, "range", req.Header.Get("Range"), "status", res.StatusCode, "duration", time.Since(start))
+
+	return res, nil
+}
+
+// newClient returns a copy of Client with the download auth, cookies, TLS and redirect policy.
+func (d *Download) newClient() (*http.Client, error) {
+
+	client := *d.Client
+	client.CheckRedirect = d.checkRedirect
+
+	if d.Jar != nil {
+		client.Jar = d.Jar
+	}
+
+	if d.TLS != nil {
+
+		t, err := d.TLS.Transport(client.Transport)
+		if err != nil {
+			return nil, err
+		}
+
+		client.Transport = t
+	}
+
+	if d.Auth != nil {
+
+		base := client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+
+		t, err := newAuthTransport(base, d.Auth, d.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		client.Transport = t
+	}
+
+	return &client, nil
+}
+
+// checkRedirect applies the download redirect policy, strips credentials when
+// redirected to another origin, then applies the Client redirect policy.
+func (d *Download) checkRedirect(req *http.Request, via []*http.Request) error {
+
+	max := d.MaxRedirects
+
+	// The redirect response is returned, it fails with its status code.
+	if max < 0 {
+		return http.ErrUseLastResponse
+	}
+
+	if max == 0 {
+		max = DefaultMaxRedirects
+	}
+
+	if len(via) > max {
+		return fmt.Errorf("%w: stopped after %d redirects", ErrTooManyRedirects, len(via)-1)
+	}
+
+	if via[len(via)-1].URL.Scheme == "https" && req.URL.Scheme == "http" && !d.AllowDowngrade {
+		return fmt.Errorf("%w: %s", ErrRedirectDowngrade, req.URL)
+	}
+
+	if originOf(req.URL) != originOf(via[0].URL) {
+		req.Header.Del("Authorization")
+		req.Header.Del("Cookie")
+	}
+
+	if d.Client.CheckRedirect != nil {
+		return d.Client.CheckRedirect(req, via)
+	}
+
+	return nil
+}
+
+// redirectChain returns the URLs the request was redirected to.
+func redirectChain(res *http.Response) (chain []string) {
+
+	for req := res.Request; req.Response != nil; req = req.Response.Request {
+		chain = append([]string{req.URL.String()}, chain...)
+	}
+
+	return chain
+}
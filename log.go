@@ -0,0 +1,182 @@
+package got
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+
+	// LogLevel is the level of a log record, the values match the slog levels.
+	LogLevel int
+
+	// Logger logs structured records, keyvals are alternating string keys and values like slog.
+	Logger interface {
+		Log(level LogLevel, msg string, keyvals ...interface{})
+	}
+
+	// LoggerFunc adapts a function to the Logger interface, e.g. to forward the records to slog:
+	//
+	//	got.LoggerFunc(func(level got.LogLevel, msg string, keyvals ...interface{}) {
+	//		logger.Log(ctx, slog.Level(level), msg, keyvals...)
+	//	})
+	LoggerFunc func(level LogLevel, msg string, keyvals ...interface{})
+
+	// textLogger writes the records as "time LEVEL msg key=value" lines.
+	textLogger struct {
+		mu sync.Mutex
+
+		w io.Writer
+
+		level LogLevel
+	}
+)
+
+// Log levels.
+const (
+	LogDebug LogLevel = -4
+	LogInfo  LogLevel = 0
+	LogWarn  LogLevel = 4
+	LogError LogLevel = 8
+)
+
+func (l LogLevel) String() string {
+
+	switch {
+	case l < LogInfo:
+		return "DEBUG"
+	case l < LogWarn:
+		return "INFO"
+	case l < LogError:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// Log calls fn.
+func (fn LoggerFunc) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	fn(level, msg, keyvals...)
+}
+
+// NewTextLogger returns a Logger writing the records from level as text lines to w.
+func NewTextLogger(w io.Writer, level LogLevel) Logger {
+	return &textLogger{w: w, level: level}
+}
+
+func (t *textLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+
+	if level < t.level {
+		return
+	}
+
+	var b strings.Builder
+
+	b.WriteString(time.Now().Format(time.RFC3339))
+	b.WriteString(" " + level.String() + " " + msg)
+
+	for i := 0; i < len(keyvals); i += 2 {
+
+		key := fmt.Sprint(keyvals[i])
+
+		var value interface{} = "!MISSING"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+
+		s := fmt.Sprint(value)
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			s = strconv.Quote(s)
+		}
+
+		b.WriteString(" " + key + "=" + s)
+	}
+
+	b.WriteByte('\n')
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	io.WriteString(t.w, b.String())
+}
+
+// redactedParams are the query parameters holding signatures, tokens and keys, in lower case.
+var redactedParams = map[string]bool{
+	"x-amz-signature":      true,
+	"x-amz-credential":     true,
+	"x-amz-security-token": true,
+	"x-goog-signature":     true,
+	"x-goog-credential":    true,
+	"signature":            true,
+	"sig":                  true,
+	"token":                true,
+	"access_token":         true,
+	"key":                  true,
+	"api_key":              true,
+	"password":             true,
+}
+
+// log logs a record when the download has a Logger, the URL values of the "url" and "*_url" keys
+// and the URLs of the errors are redacted.
+func (d *Download) log(level LogLevel, msg string, keyvals ...interface{}) {
+
+	if d.Logger == nil {
+		return
+	}
+
+	for i := 1; i < len(keyvals); i += 2 {
+
+		key, _ := keyvals[i-1].(string)
+
+		switch v := keyvals[i].(type) {
+		case *url.URL:
+			keyvals[i] = redactURL(v.String())
+		case string:
+			if key == "url" || strings.HasSuffix(key, "_url") {
+				keyvals[i] = redactURL(v)
+			}
+		case error:
+			if uerr := (*url.Error)(nil); errors.As(v, &uerr) {
+				keyvals[i] = strings.ReplaceAll(v.Error(), uerr.URL, redactURL(uerr.URL))
+			}
+		}
+	}
+
+	d.Logger.Log(level, msg, keyvals...)
+}
+
+// redactURL returns the URL without its user info and with the values of the signature,
+// token and key query parameters replaced by "xxxxx".
+func redactURL(rawURL string) string {
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "!INVALID"
+	}
+
+	u.User = nil
+
+	if u.RawQuery != "" {
+
+		params := strings.Split(u.RawQuery, "&")
+
+		for i, param := range params {
+
+			raw, _, _ := strings.Cut(param, "=")
+
+			if name, err := url.QueryUnescape(raw); err == nil && redactedParams[strings.ToLower(name)] {
+				params[i] = raw + "=xxxxx"
+			}
+		}
+
+		u.RawQuery = strings.Join(params, "&")
+	}
+
+	return u.String()
+}
@@ -0,0 +1,133 @@
+package got_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/melbahja/got"
+)
+
+func TestLogger(t *testing.T) {
+
+	var (
+		mu      sync.Mutex
+		records = make(map[string]got.LogLevel)
+	)
+
+	tmpFile := createTemp()
+	defer clean(tmpFile)
+
+	d := got.NewDownload(context.Background(), httpt.URL+"/ok_file", tmpFile)
+	d.Logger = got.LoggerFunc(func(level got.LogLevel, msg string, keyvals ...interface{}) {
+		mu.Lock()
+		records[msg] = level
+		mu.Unlock()
+	})
+
+	if err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]got.LogLevel{
+		"probe":             got.LogInfo,
+		"chunks":            got.LogDebug,
+		"request":           got.LogDebug,
+		"download complete": got.LogInfo,
+	}
+
+	for msg, level := range expected {
+		if l, ok := records[msg]; !ok || l != level {
+			t.Errorf("Expecting %s record at %s level, got: %v", msg, level, records)
+		}
+	}
+
+	d = got.NewDownload(context.Background(), httpt.URL+"/not_found", createTemp())
+	d.Logger = got.LoggerFunc(func(level got.LogLevel, msg string, keyvals ...interface{}) {
+		records[msg] = level
+	})
+	defer clean(d.Dest)
+
+	if err := d.Init(); err == nil {
+		t.Fatal("Expecting init error")
+	}
+
+	if records["download init failed"] != got.LogError {
+		t.Errorf("Expecting download init failed error record, got: %v", records)
+	}
+}
+
+func TestLoggerRedaction(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		URL   string
+		valid bool
+	}{
+		{srv.URL, true},
+		{closed.URL, false},
+	}
+
+	for _, test := range tests {
+
+		out := new(bytes.Buffer)
+
+		u := strings.Replace(test.URL, "://", "://user:secret-pass@", 1) + "/ok_file?id=1&X-Amz-Signature=secret-sig&access_token=secret-token"
+
+		d := got.NewDownload(context.Background(), u, createTemp())
+		d.Logger = got.NewTextLogger(out, got.LogDebug)
+		defer clean(d.Dest)
+
+		if err := d.Init(); (err == nil) != test.valid {
+			t.Fatalf("Expecting %s init valid %v, got: %v", test.URL, test.valid, err)
+		}
+
+		if test.valid {
+			if err := d.Start(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		logs := out.String()
+
+		if strings.Contains(logs, "secret") || !strings.Contains(logs, "/ok_file?id=1&X-Amz-Signature=xxxxx&access_token=xxxxx") {
+			t.Errorf("Expecting redacted URLs, got: %s", logs)
+		}
+	}
+}
+
+func TestTextLogger(t *testing.T) {
+
+	out := new(bytes.Buffer)
+	logger := got.NewTextLogger(out, got.LogInfo)
+
+	logger.Log(got.LogDebug, "hidden", "key", "value")
+	logger.Log(got.LogWarn, "chunk failed", "range", "0-99", "error", "read: connection reset", "empty", "")
+
+	line := out.String()
+
+	if strings.Contains(line, "hidden") {
+		t.Errorf("Expecting debug record to be skipped: %s", line)
+	}
+
+	expected := ` WARN chunk failed range=0-99 error="read: connection reset" empty=""` + "\n"
+
+	if !strings.HasSuffix(line, expected) {
+		t.Errorf("Expecting line ending with %q, got: %q", expected, line)
+	}
+}
@@ -0,0 +1,121 @@
+package got
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+type netrcMachine struct {
+	name, login, password string
+}
+
+// lookupNetrc returns the netrc machine entry of host, or the default entry if any.
+func lookupNetrc(path, host string) (*netrcMachine, error) {
+
+	if path == "" {
+		path = netrcPath()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+
+		// Missing netrc file is not an error.
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var def *netrcMachine
+
+	for _, m := range parseNetrc(string(data)) {
+
+		if m.name == "" && def == nil {
+			def = m
+		}
+
+		if m.name != "" && strings.EqualFold(m.name, host) {
+			return m, nil
+		}
+	}
+
+	return def, nil
+}
+
+// parseNetrc parses netrc machines, the default entry has an empty name.
+func parseNetrc(data string) (machines []*netrcMachine) {
+
+	var (
+		m      *netrcMachine
+		fields = strings.Fields(data)
+	)
+
+	for i := 0; i < len(fields); i++ {
+
+		switch fields[i] {
+		case "machine":
+			if i++; i < len(fields) {
+				m = &netrcMachine{name: fields[i]}
+				machines = append(machines, m)
+			}
+		case "default":
+			m = &netrcMachine{}
+			machines = append(machines, m)
+		case "login", "password", "account":
+			if i++; i < len(fields) && m != nil {
+				switch fields[i-1] {
+				case "login":
+					m.login = fields[i]
+				case "password":
+					m.password = fields[i]
+				}
+			}
+		case "macdef":
+			// Macros end with an empty line.
+			m = nil
+			if end := macdefEnd(data, fields, i); end > i {
+				i = end
+			}
+		}
+	}
+
+	return machines
+}
+
+// macdefEnd returns the index of the last field of the macro starting at fields[i].
+func macdefEnd(data string, fields []string, i int) int {
+
+	// Find the macro position in the raw data to locate the empty line.
+	pos := 0
+	for j := 0; j <= i; j++ {
+		pos += strings.Index(data[pos:], fields[j]) + len(fields[j])
+	}
+
+	end := strings.Index(data[pos:], "\n\n")
+	if end == -1 {
+		return len(fields)
+	}
+
+	return i + len(strings.Fields(data[pos:pos+end]))
+}
+
+func netrcPath() string {
+
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc")
+	}
+
+	return filepath.Join(home, ".netrc")
+}
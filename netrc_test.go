@@ -0,0 +1,39 @@
+package got
+
+import (
+	"testing"
+)
+
+func TestParseNetrc(t *testing.T) {
+
+	data := `
+machine example.com login foo password bar
+
+macdef init
+cd /pub
+machine evil.com login evil password evil
+
+machine other.com
+	login other
+	password "secret"
+default login anonymous password user@example.com
+`
+
+	machines := parseNetrc(data)
+
+	expected := []netrcMachine{
+		{name: "example.com", login: "foo", password: "bar"},
+		{name: "other.com", login: "other", password: `"secret"`},
+		{name: "", login: "anonymous", password: "user@example.com"},
+	}
+
+	if len(machines) != len(expected) {
+		t.Fatalf("Expecting %d machines but got %d", len(expected), len(machines))
+	}
+
+	for i, m := range machines {
+		if *m != expected[i] {
+			t.Errorf("Expecting machine %+v but got %+v", expected[i], *m)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package got
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Protocol downloads the resources of a URL scheme.
+type Protocol interface {
+
+	// Probe returns the resource info, when the resource is not rangeable
+	// it returns a reader of the whole content.
+	Probe(d *Download) (*Info, io.ReadCloser, error)
+
+	// Open returns a reader of the chunk bytes range.
+	Open(d *Download, c *Chunk) (io.ReadCloser, error)
+}
+
+// ErrUnsupportedProtocol is returned when no protocol is registered for the URL scheme.
+var ErrUnsupportedProtocol = errors.New("Unsupported protocol")
+
+var (
+	protocolsMu sync.RWMutex
+
+	protocols = map[string]Protocol{
+		"http":  httpProtocol{},
+		"https": httpProtocol{},
+		"file":  fileProtocol{},
+		"data":  dataProtocol{},
+		"ftp":   ftpProtocol{},
+		"ftps":  ftpProtocol{},
+		"s3":    s3Protocol{},
+	}
+)
+
+// RegisterProtocol registers the protocol of a URL scheme,
+// it replaces the protocol already registered for the scheme if any.
+func RegisterProtocol(scheme string, p Protocol) {
+
+	protocolsMu.Lock()
+	defer protocolsMu.Unlock()
+
+	protocols[strings.ToLower(scheme)] = p
+}
+
+func getProtocol(URL string) (Protocol, error) {
+
+	// The scheme is read without parsing the URL, since data URLs may not be valid URLs.
+	scheme := ""
+	if i := strings.IndexByte(URL, ':'); i > 0 {
+		scheme = strings.ToLower(URL[:i])
+	}
+
+	protocolsMu.RLock()
+	defer protocolsMu.RUnlock()
+
+	if p, ok := protocols[scheme]; ok {
+		return p, nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedProtocol, scheme)
+}
+
+// fileProtocol copies local files, chunks are copied concurrently.
+type fileProtocol struct{}
+
+var windowsDrive = regexp.MustCompile(`^/[a-zA-Z]:`)
+
+// Probe returns the local file info.
+func (fileProtocol) Probe(d *Download) (*Info, io.ReadCloser, error) {
+
+	name, err := filePath(d.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat, err := os.Stat(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if stat.IsDir() {
+		return nil, nil, fmt.Errorf("Cannot download a directory: %s", name)
+	}
+
+	info := &Info{
+		Size:      uint64(stat.Size()),
+		Rangeable: stat.Size() > 0,
+		URL:       d.URL,
+	}
+
+	if info.Rangeable {
+		return info, nil, nil
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return info, file, nil
+}
+
+// Open returns a reader of the chunk section of the file.
+func (fileProtocol) Open(d *Download, c *Chunk) (io.ReadCloser, error) {
+
+	name, err := filePath(d.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(file, int64(c.Start), int64(c.End-c.Start+1)), file}, nil
+}
+
+// filePath returns the local path of a file URL.
+func filePath(URL string) (string, error) {
+
+	u, err := url.Parse(URL)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("Remote file URL host is not supported: %s", u.Host)
+	}
+
+	name := u.Path
+
+	// file:///C:/path on windows.
+	if windowsDrive.MatchString(name) {
+		name = name[1:]
+	}
+
+	return filepath.FromSlash(name), nil
+}
+
+// dataProtocol decodes RFC 2397 data URLs.
+type dataProtocol struct{}
+
+// Probe decodes the data URL content.
+func (dataProtocol) Probe(d *Download) (*Info, io.ReadCloser, error) {
+
+	data, err := decodeDataURL(d.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info := &Info{
+		Size: uint64(len(data)),
+		URL:  d.URL,
+	}
+
+	return info, io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Open returns the chunk range of the data URL content.
+func (dataProtocol) Open(d *Download, c *Chunk) (io.ReadCloser, error) {
+
+	data, err := decodeDataURL(d.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.End >= uint64(len(data)) {
+		return nil, fmt.Errorf("Invalid data URL range: %d-%d", c.Start, c.End)
+	}
+
+	return io.NopCloser(bytes.NewReader(data[c.Start : c.End+1])), nil
+}
+
+func decodeDataURL(URL string) ([]byte, error) {
+
+	i := strings.IndexByte(URL, ',')
+	if i == -1 || !strings.HasPrefix(strings.ToLower(URL), "data:") {
+		return nil, fmt.Errorf("Invalid data URL")
+	}
+
+	meta, data := URL[len("data:"):i], URL[i+1:]
+
+	decoded, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(strings.ToLower(meta), ";base64") {
+		return []byte(decoded), nil
+	}
+
+	decoded = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, decoded)
+
+	return base64.StdEncoding.DecodeString(decoded)
+}
@@ -0,0 +1,98 @@
+package got_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/melbahja/got"
+)
+
+// memProtocol serves the URL host bytes repeated, for testing third party protocols.
+type memProtocol struct{}
+
+func (memProtocol) Probe(d *got.Download) (*got.Info, io.ReadCloser, error) {
+	return &got.Info{Size: uint64(len(memContent(d))), Rangeable: true}, nil, nil
+}
+
+func (memProtocol) Open(d *got.Download, c *got.Chunk) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(memContent(d)[c.Start : c.End+1])), nil
+}
+
+func memContent(d *got.Download) []byte {
+	u, _ := url.Parse(d.URL)
+	return bytes.Repeat([]byte(u.Host), 100)
+}
+
+func TestProtocols(t *testing.T) {
+
+	got.RegisterProtocol("mem", memProtocol{})
+
+	mod, err := ioutil.ReadFile("go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	abs, err := filepath.Abs("go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		URL      string
+		expected []byte
+	}{
+		{"file://" + filepath.ToSlash(abs), mod},
+		{"data:,Hello%2C%20World%21", []byte("Hello, World!")},
+		{"data:text/plain;base64,SGVsbG8sIFdvcmxkIQ==", []byte("Hello, World!")},
+		{"data:text/plain,100%", nil},
+		{"mem://got", bytes.Repeat([]byte("got"), 100)},
+		{"unknown://got", nil},
+	}
+
+	for _, test := range tests {
+
+		tmpFile := createTemp()
+		defer clean(tmpFile)
+
+		d := &got.Download{
+			URL:       test.URL,
+			Dest:      tmpFile,
+			ChunkSize: 10,
+		}
+
+		err := d.Init()
+		if err == nil {
+			err = d.Start()
+		}
+
+		if test.expected == nil {
+			if err == nil {
+				t.Errorf("Expecting error for %s", test.URL)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", test.URL, err)
+			continue
+		}
+
+		data, _ := ioutil.ReadFile(tmpFile)
+
+		if !bytes.Equal(data, test.expected) {
+			t.Errorf("Expecting %s content: %q, but got %q", test.URL, test.expected, data)
+		}
+	}
+
+	tmpFile := createTemp()
+	defer clean(tmpFile)
+
+	if err := got.New().Download("unknown://got", tmpFile); !errors.Is(err, got.ErrUnsupportedProtocol) {
+		t.Errorf("Expecting unsupported protocol error, but got %v", err)
+	}
+}
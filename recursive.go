@@ -0,0 +1,341 @@
+package got
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// RecursiveConfig holds the options of recursive directory downloads.
+type RecursiveConfig struct {
+
+	// Include and Exclude are path.Match patterns matched against the file path
+	// relative to the start URL or its base name, excluded directories are not listed.
+	Include, Exclude []string
+
+	// Depth is the maximum number of directory levels to follow, 1 only downloads
+	// the start directory files, 0 is unlimited.
+	Depth int
+
+	// Parallel is the number of files downloaded concurrently, defaults to 4.
+	Parallel uint
+
+	// Prepare is called with each request download before it starts, e.g. to set the request headers.
+	Prepare func(d *Download)
+
+	// Done is called when a file download is done.
+	Done func(d *Download, err error)
+
+	// SkipUnchanged skips the files with the same ETag as their last download and the same local size,
+	// Done is called with ErrUnchanged for them. The ETags are saved in the .got.etags file of the download directory.
+	SkipUnchanged bool
+}
+
+// remoteFile is a file of a remote directory tree.
+type remoteFile struct {
+	URL string
+
+	// Path is the slash separated path relative to the start directory.
+	Path string
+
+	// Size and ETag are the listed file size and ETag, if known.
+	Size uint64
+	ETag string
+}
+
+var (
+	htmlTag  = regexp.MustCompile(`(?is)<([a-z][a-z0-9]*)\s([^>]*)>`)
+	htmlAttr = regexp.MustCompile(`(?is)([a-z][a-z0-9-]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// DownloadRecursive downloads the files of an HTTP directory listing and its subdirectories
+// into dir, the directory structure below the start URL is recreated.
+func (g Got) DownloadRecursive(URL, dir string, c *RecursiveConfig) error {
+
+	if c == nil {
+		c = &RecursiveConfig{}
+	}
+
+	files, err := g.listIndex(URL, dir, c)
+	if err != nil {
+		return err
+	}
+
+	return g.downloadFiles(files, dir, c)
+}
+
+// listIndex lists the files of the HTML index pages below URL, and creates the directories.
+func (g Got) listIndex(URL, dir string, c *RecursiveConfig) ([]remoteFile, error) {
+
+	type page struct {
+		URL   string
+		depth int
+	}
+
+	var (
+		files   []remoteFile
+		queue   = []page{{URL, 1}}
+		visited = make(map[string]bool)
+		root    *url.URL
+	)
+
+	for len(queue) > 0 {
+
+		p := queue[0]
+		queue = queue[1:]
+
+		data, base, err := g.newRequestDownload(p.URL, c).getManifest(p.URL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.URL, err)
+		}
+
+		// The start URL is a directory, even without a trailing slash.
+		if root == nil {
+
+			if !strings.HasSuffix(base.Path, "/") {
+				base.Path, base.RawPath = base.Path+"/", ""
+			}
+
+			root = &url.URL{Scheme: base.Scheme, Host: base.Host, Path: base.Path}
+			visited[root.Path] = true
+		}
+
+		for _, link := range htmlLinks(data, map[string][]string{"a": {"href"}}) {
+
+			u, err := base.Parse(link)
+			if err != nil || u.Host != root.Host || u.RawQuery != "" || !strings.HasPrefix(u.Path, root.Path) || visited[u.Path] {
+				continue
+			}
+
+			visited[u.Path] = true
+			u.Fragment = ""
+
+			rel := strings.TrimPrefix(u.Path, root.Path)
+			isDir := strings.HasSuffix(rel, "/")
+			rel = strings.TrimSuffix(rel, "/")
+
+			if rel == "" || !validRelPath(rel) || matchAny(c.Exclude, rel) {
+				continue
+			}
+
+			if isDir {
+
+				if c.Depth > 0 && p.depth >= c.Depth {
+					continue
+				}
+
+				if err = os.MkdirAll(filepath.Join(dir, filepath.FromSlash(rel)), os.ModePerm); err != nil {
+					return nil, err
+				}
+
+				queue = append(queue, page{u.String(), p.depth + 1})
+				continue
+			}
+
+			if len(c.Include) > 0 && !matchAny(c.Include, rel) {
+				continue
+			}
+
+			files = append(files, remoteFile{URL: u.String(), Path: rel})
+		}
+	}
+
+	return files, nil
+}
+
+// downloadFiles downloads the files into dir concurrently, it returns the first error.
+func (g Got) downloadFiles(files []remoteFile, dir string, c *RecursiveConfig) (err error) {
+
+	parallel := c.Parallel
+	if parallel == 0 {
+		parallel = 4
+	}
+
+	var (
+		wg    sync.WaitGroup
+		slots = make(chan struct{}, parallel)
+		errs  = make(chan error, 1)
+		ctx   = g.ctx
+	)
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var tags *etags
+
+	if c.SkipUnchanged {
+
+		if tags, err = loadETags(dir); err != nil {
+			return err
+		}
+
+		// The ETags of the downloaded files are saved even if another file failed.
+		defer func() {
+			if serr := tags.save(); serr != nil && err == nil {
+				err = serr
+			}
+		}()
+	}
+
+	for _, f := range files {
+
+		select {
+		case slots <- struct{}{}:
+		case err := <-errs:
+			wg.Wait()
+			return err
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+
+		dest := filepath.Join(dir, filepath.FromSlash(f.Path))
+
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			wg.Wait()
+			return err
+		}
+
+		d := &Download{URL: f.URL, Dest: dest}
+
+		if tags != nil && tags.unchanged(f, dest) {
+
+			<-slots
+
+			if c.Done != nil {
+				c.Done(d, ErrUnchanged)
+			}
+
+			continue
+		}
+
+		if c.Prepare != nil {
+			c.Prepare(d)
+		}
+
+		wg.Add(1)
+
+		go func(d *Download, f remoteFile) {
+
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			err := g.Do(d)
+
+			if err == nil && tags != nil {
+				tags.set(f)
+			}
+
+			if c.Done != nil {
+				c.Done(d, err)
+			}
+
+			if err != nil {
+				select {
+				case errs <- fmt.Errorf("%s: %w", d.URL, err):
+				default:
+				}
+			}
+		}(d, f)
+	}
+
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// newRequestDownload returns a download used to send listing requests.
+func (g Got) newRequestDownload(URL string, c *RecursiveConfig) *Download {
+
+	d := &Download{URL: URL}
+
+	if c.Prepare != nil {
+		c.Prepare(d)
+	}
+
+	g.setDefaults(d)
+
+	if d.ctx == nil {
+		d.ctx = context.Background()
+	}
+
+	if d.Client == nil {
+		d.Client = DefaultClient
+	}
+
+	return d
+}
+
+// htmlLinks returns the unescaped link attribute values of the page tags, e.g. {"a": {"href"}}.
+func htmlLinks(page []byte, attrs map[string][]string) (links []string) {
+
+	for _, tag := range htmlTag.FindAllSubmatch(page, -1) {
+
+		names := attrs[strings.ToLower(string(tag[1]))]
+		if len(names) == 0 {
+			continue
+		}
+
+		for _, attr := range htmlAttr.FindAllSubmatch(tag[2], -1) {
+
+			name := strings.ToLower(string(attr[1]))
+
+			for _, n := range names {
+
+				if n != name {
+					continue
+				}
+
+				value := string(attr[2]) + string(attr[3]) + string(attr[4])
+
+				if value = strings.TrimSpace(html.UnescapeString(value)); value != "" {
+					links = append(links, value)
+				}
+			}
+		}
+	}
+
+	return links
+}
+
+// matchAny reports whether the relative path or its base name matches any pattern.
+func matchAny(patterns []string, rel string) bool {
+
+	for _, p := range patterns {
+
+		if ok, _ := path.Match(p, rel); ok {
+			return true
+		}
+
+		if ok, _ := path.Match(p, path.Base(rel)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validRelPath reports whether rel is a relative path that stays below its root.
+func validRelPath(rel string) bool {
+
+	for _, part := range strings.Split(rel, "/") {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, "\\\x00") {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,142 @@
+package got_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/melbahja/got"
+)
+
+func TestDownloadRecursive(t *testing.T) {
+
+	root := t.TempDir()
+
+	tree := map[string]string{
+		"a.txt":           "a",
+		"b.log":           "b",
+		"sub/c.txt":       "c",
+		"sub/deep/d.txt":  "d",
+		"skip/e.txt":      "e",
+		"empty/":          "",
+		"with space.txt":  "space",
+		"sub/deep/f.html": "<a href=\"../../../secret.txt\">up</a>",
+	}
+
+	for name, content := range tree {
+
+		path := filepath.Join(root, "pub", filepath.FromSlash(name))
+
+		if strings.HasSuffix(name, "/") {
+			os.MkdirAll(path, 0755)
+			continue
+		}
+
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+
+	os.WriteFile(filepath.Join(root, "secret.txt"), []byte("secret"), 0644)
+
+	srv := httptest.NewServer(http.FileServer(http.Dir(root)))
+	defer srv.Close()
+
+	t.Run("mirrorTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		var (
+			mu   sync.Mutex
+			done []string
+		)
+
+		err := got.New().DownloadRecursive(srv.URL+"/pub", dir, &got.RecursiveConfig{
+			Done: func(d *got.Download, err error) {
+				mu.Lock()
+				done = append(done, d.URL)
+				mu.Unlock()
+			},
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectTree(t, dir, []string{"a.txt", "b.log", "empty/", "skip/", "skip/e.txt", "sub/", "sub/c.txt", "sub/deep/", "sub/deep/d.txt", "sub/deep/f.html", "with space.txt"})
+
+		if len(done) != 7 {
+			t.Errorf("Expecting 7 done downloads, but got %d", len(done))
+		}
+
+		if data, _ := os.ReadFile(filepath.Join(dir, "with space.txt")); string(data) != "space" {
+			t.Errorf("Expecting file content space, but got %s", data)
+		}
+	})
+
+	t.Run("filtersTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		err := got.New().DownloadRecursive(srv.URL+"/pub/", dir, &got.RecursiveConfig{
+			Include: []string{"*.txt"},
+			Exclude: []string{"skip", "sub/deep"},
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectTree(t, dir, []string{"a.txt", "empty/", "sub/", "sub/c.txt", "with space.txt"})
+	})
+
+	t.Run("depthTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		if err := got.New().DownloadRecursive(srv.URL+"/pub/", dir, &got.RecursiveConfig{Depth: 2, Parallel: 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		expectTree(t, dir, []string{"a.txt", "b.log", "empty/", "skip/", "skip/e.txt", "sub/", "sub/c.txt", "with space.txt"})
+	})
+
+	t.Run("notFoundTest", func(t *testing.T) {
+
+		if err := got.New().DownloadRecursive(srv.URL+"/404/", t.TempDir(), nil); err == nil {
+			t.Error("Expecting not found error")
+		}
+	})
+}
+
+func expectTree(t *testing.T, dir string, expect []string) {
+
+	var tree []string
+
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+
+		if err != nil || path == dir {
+			return err
+		}
+
+		rel, _ := filepath.Rel(dir, path)
+		rel = filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			rel += "/"
+		}
+
+		tree = append(tree, rel)
+		return nil
+	})
+
+	sort.Strings(tree)
+
+	if strings.Join(tree, ",") != strings.Join(expect, ",") {
+		t.Errorf("Expecting tree %v, but got %v", expect, tree)
+	}
+}
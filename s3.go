@@ -0,0 +1,391 @@
+package got
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 addressing styles.
+const (
+	S3PathStyle        = "path"
+	S3VirtualHostStyle = "virtual"
+)
+
+// S3Config holds the options of s3://bucket/key downloads, the unset options are read from
+// the AWS environment variables, then from the AWS shared config and credentials files.
+type S3Config struct {
+
+	// Endpoint is the URL of an S3 compatible server, e.g. http://localhost:9000 for MinIO,
+	// defaults to the AWS S3 endpoint of the region.
+	Endpoint string
+
+	// Region defaults to us-east-1.
+	Region string
+
+	// AccessKeyID and SecretAccessKey are the signing credentials, requests are not signed without credentials.
+	AccessKeyID, SecretAccessKey string
+
+	// SessionToken is the temporary credentials session token.
+	SessionToken string
+
+	// Profile is the shared files profile, defaults to $AWS_PROFILE or "default".
+	Profile string
+
+	// Addressing is S3PathStyle or S3VirtualHostStyle, by default the path style is used
+	// for custom endpoints and for bucket names that are not valid host labels.
+	Addressing string
+}
+
+// s3Protocol downloads s3://bucket/key URLs, the probe and chunk requests are signed with AWS Signature V4.
+type s3Protocol struct{}
+
+// s3Object is the resolved object URL and signing credentials.
+type s3Object struct {
+	URL string
+
+	region, accessKey, secretKey, token string
+}
+
+// emptySHA256 is the hex sha256 hash of an empty payload.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+var s3BucketHost = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$`)
+
+// Probe resolves the object URL and sends the signed probe request.
+func (s3Protocol) Probe(d *Download) (*Info, io.ReadCloser, error) {
+
+	obj, err := resolveS3(d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d.s3Object = obj
+
+	info, body, err := d.probeHTTP(obj.URL, obj.sign)
+
+	// S3 rejects ranges of empty objects.
+	var status statusError
+	if errors.As(err, &status) && status == http.StatusRequestedRangeNotSatisfiable {
+		return &Info{URL: obj.URL}, io.NopCloser(strings.NewReader("")), nil
+	}
+
+	return info, body, err
+}
+
+// Open sends the signed chunk range request.
+func (s3Protocol) Open(d *Download, c *Chunk) (io.ReadCloser, error) {
+
+	if d.s3Object == nil {
+		return nil, fmt.Errorf("S3 object of %s is not resolved", d.URL)
+	}
+
+	return d.openHTTP(c, d.s3Object.sign)
+}
+
+// resolveS3 returns the http URL and credentials of the download S3 object.
+func resolveS3(d *Download) (*s3Object, error) {
+
+	u, err := url.Parse(d.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("Invalid S3 URL, expecting s3://bucket/key: %s", d.URL)
+	}
+
+	c := d.S3
+	if c == nil {
+		c = &S3Config{}
+	}
+
+	profile := firstOf(c.Profile, os.Getenv("AWS_PROFILE"), "default")
+
+	config, err := loadAWSFile(firstOf(os.Getenv("AWS_CONFIG_FILE"), awsPath("config")), profile, true)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := loadAWSFile(firstOf(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), awsPath("credentials")), profile, false)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &s3Object{
+		region: firstOf(c.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), config["region"], "us-east-1"),
+	}
+
+	switch {
+	case c.AccessKeyID != "":
+		obj.accessKey, obj.secretKey, obj.token = c.AccessKeyID, c.SecretAccessKey, c.SessionToken
+	case os.Getenv("AWS_ACCESS_KEY_ID") != "":
+		obj.accessKey, obj.secretKey, obj.token = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")
+	case creds["aws_access_key_id"] != "":
+		obj.accessKey, obj.secretKey, obj.token = creds["aws_access_key_id"], creds["aws_secret_access_key"], creds["aws_session_token"]
+	default:
+		obj.accessKey, obj.secretKey, obj.token = config["aws_access_key_id"], config["aws_secret_access_key"], config["aws_session_token"]
+	}
+
+	endpoint := firstOf(c.Endpoint, os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL"), config["s3.endpoint_url"], config["endpoint_url"])
+	custom := endpoint != ""
+
+	if !custom {
+		endpoint = "https://s3." + obj.region + ".amazonaws.com"
+	}
+
+	e, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Scheme == "" || e.Host == "" {
+		return nil, fmt.Errorf("Invalid S3 endpoint: %s", endpoint)
+	}
+
+	style := firstOf(c.Addressing, config["s3.addressing_style"])
+	pathStyle := style == S3PathStyle || (style != S3VirtualHostStyle && (custom || !s3BucketHost.MatchString(bucket)))
+
+	e.Path = strings.TrimSuffix(e.Path, "/")
+
+	if pathStyle {
+		e.Path += "/" + bucket + "/" + key
+	} else {
+		e.Host = bucket + "." + e.Host
+		e.Path += "/" + key
+	}
+
+	e.RawPath = s3Escape(e.Path, false)
+	e.RawQuery = ""
+	obj.URL = e.String()
+
+	return obj, nil
+}
+
+// sign signs the request with AWS Signature V4, anonymous requests are not signed.
+func (o *s3Object) sign(req *http.Request) error {
+
+	if o.accessKey != "" {
+		signS3(req, o.accessKey, o.secretKey, o.token, o.region, time.Now().UTC())
+	}
+
+	return nil
+}
+
+// signS3 signs a request without payload using AWS Signature V4,
+// the host, range and x-amz-* headers are signed.
+func signS3(req *http.Request, accessKey, secretKey, token, region string, now time.Time) {
+
+	date := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", date)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+
+	if token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+
+	for k, values := range req.Header {
+
+		k = strings.ToLower(k)
+
+		if k != "range" && !strings.HasPrefix(k, "x-amz-") {
+			continue
+		}
+
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+
+		headers[k] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+
+	canonical.WriteString(req.Method + "\n")
+	canonical.WriteString(req.URL.EscapedPath() + "\n")
+	canonical.WriteString(s3Query(req.URL.Query()) + "\n")
+
+	for _, k := range names {
+		canonical.WriteString(k + ":" + headers[k] + "\n")
+	}
+
+	signed := strings.Join(names, ";")
+	canonical.WriteString("\n" + signed + "\n" + emptySHA256)
+
+	hash := sha256.Sum256([]byte(canonical.String()))
+	stringToSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{now.Format("20060102"), region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, stringToSign)),
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Query returns the canonical query string.
+func s3Query(query url.Values) string {
+
+	params := make([]string, 0, len(query))
+
+	for k, values := range query {
+		for _, v := range values {
+			params = append(params, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+// s3Escape percent-encodes all bytes except the unreserved characters, and "/" unless slash is set.
+func s3Escape(s string, slash bool) string {
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+
+		c := s[i]
+
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !slash) {
+			b.WriteByte(c)
+			continue
+		}
+
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
+
+// loadAWSFile returns the profile keys of an AWS shared config or credentials file,
+// nested keys are prefixed with their section key, e.g. "s3.addressing_style".
+func loadAWSFile(path, profile string, config bool) (map[string]string, error) {
+
+	values := make(map[string]string)
+
+	if path == "" {
+		return values, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return values, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	// The config file sections are named "profile name" except the default profile.
+	section := profile
+	if config && profile != "default" {
+		section = "profile " + profile
+	}
+
+	var (
+		scanner = bufio.NewScanner(file)
+		inside  bool
+		parent  string
+	)
+
+	for scanner.Scan() {
+
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';' {
+			continue
+		}
+
+		if trimmed[0] == '[' && strings.HasSuffix(trimmed, "]") {
+			inside = strings.Join(strings.Fields(trimmed[1:len(trimmed)-1]), " ") == section
+			parent = ""
+			continue
+		}
+
+		i := strings.IndexByte(trimmed, '=')
+		if !inside || i == -1 {
+			continue
+		}
+
+		k, v := strings.ToLower(strings.TrimSpace(trimmed[:i])), strings.TrimSpace(trimmed[i+1:])
+
+		switch {
+		case line[0] == ' ' || line[0] == '\t':
+			if parent != "" {
+				values[parent+"."+k] = v
+			}
+		case v == "":
+			parent = k
+		default:
+			parent = ""
+			values[k] = v
+		}
+	}
+
+	return values, scanner.Err()
+}
+
+func awsPath(name string) string {
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".aws", name)
+}
+
+// firstOf returns the first non empty value.
+func firstOf(values ...string) string {
+
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
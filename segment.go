@@ -0,0 +1,203 @@
+package got
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+)
+
+// segment is a media segment URL, Range is the segment bytes range if set.
+type segment struct {
+	URL string
+
+	Range *Chunk
+
+	// decrypt decrypts the segment content if set.
+	decrypt func(data []byte) ([]byte, error)
+}
+
+type segmentResult struct {
+	data []byte
+
+	// size is the downloaded size before decryption.
+	size int
+
+	err error
+}
+
+// downloadSegments downloads the segments concurrently and passes them in order to write,
+// at most Concurrency segments are kept in memory.
+func (d *Download) downloadSegments(segments []*segment, write func(i int, data []byte) error) error {
+
+	var (
+		results  = make([]chan segmentResult, len(segments))
+		slots    = make(chan struct{}, d.Concurrency)
+		done     = make(chan struct{})
+		received uint64
+	)
+
+	defer close(done)
+
+	for i := range results {
+		results[i] = make(chan segmentResult, 1)
+	}
+
+	go func() {
+
+		for i, s := range segments {
+
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+
+			go func(i int, s *segment) {
+
+				data, err := d.getSegment(s, i)
+				size := len(data)
+
+				if err == nil && s.decrypt != nil {
+					data, err = s.decrypt(data)
+				}
+
+				results[i] <- segmentResult{data, size, err}
+			}(i, s)
+		}
+	}()
+
+	for i := range segments {
+
+		var res segmentResult
+
+		select {
+		case res = <-results[i]:
+		case <-d.ctx.Done():
+			return d.ctx.Err()
+		}
+
+		if res.err != nil {
+			return fmt.Errorf("Segment %d: %w", i, res.err)
+		}
+
+		if err := write(i, res.data); err != nil {
+			return err
+		}
+
+		<-slots
+
+		// The total size is estimated from the downloaded segments.
+		received += uint64(res.size)
+		atomic.StoreUint64(&d.info.Size, received*uint64(len(segments))/uint64(i+1))
+	}
+
+	return nil
+}
+
+// getSegment downloads a segment content.
+func (d *Download) getSegment(s *segment, index int) ([]byte, error) {
+	return d.getRange(s.URL, s.Range, RequestKind{Type: ChunkRequest, Chunk: s.Range, Index: index}, d)
+}
+
+// getRange downloads the URL bytes range, or the whole content if r is nil,
+// the downloaded bytes are written to progress if set.
+func (d *Download) getRange(URL string, r *Chunk, kind RequestKind, progress io.Writer) ([]byte, error) {
+
+	req, err := d.newRequest(http.MethodGet, URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if r != nil {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", r.Start, r.End))
+	}
+
+	if err = d.hook(req, kind); err != nil {
+		return nil, err
+	}
+
+	res, err := d.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return nil, statusError(res.StatusCode)
+	}
+
+	if r != nil && res.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("Range request is not supported: %s", URL)
+	}
+
+	var (
+		buf  bytes.Buffer
+		body io.Reader = res.Body
+	)
+
+	if progress != nil {
+		body = io.TeeReader(body, progress)
+	}
+
+	if _, err = io.Copy(&buf, body); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// getManifest downloads a playlist or manifest, it returns its content and final URL.
+func (d *Download) getManifest(URL string) ([]byte, *url.URL, error) {
+
+	res, err := d.get(URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, res.Request.URL, nil
+}
+
+// get sends a GET probe request, it returns an error for the non 2xx responses.
+func (d *Download) get(URL string) (*http.Response, error) {
+
+	req, err := d.newRequest(http.MethodGet, URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = d.hook(req, RequestKind{Type: ProbeRequest}); err != nil {
+		return nil, err
+	}
+
+	res, err := d.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 300 {
+		res.Body.Close()
+		return nil, statusError(res.StatusCode)
+	}
+
+	return res, nil
+}
+
+// resolveURL resolves a reference URL against base.
+func resolveURL(base *url.URL, ref string) (string, error) {
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+
+	return base.ResolveReference(u).String(), nil
+}
@@ -0,0 +1,593 @@
+package got
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpiderConfig holds the options of spider downloads.
+type SpiderConfig struct {
+
+	// Depth is the maximum number of link levels to follow from the start page,
+	// 1 only downloads the start page links, 0 is unlimited.
+	Depth int
+
+	// Domains are the hosts to follow, with their subdomains, defaults to the start URL host.
+	Domains []string
+
+	// Accept and Reject filter the downloaded files, a pattern with a slash is matched against
+	// the MIME type (e.g. "image/*"), a pattern without glob characters is a file extension
+	// (e.g. "pdf"), others are path.Match patterns of the file name (e.g. "report-*.pdf").
+	// Rejected pages are still followed.
+	Accept, Reject []string
+
+	// IgnoreRobots disables the robots.txt rules.
+	IgnoreRobots bool
+
+	// Delay is the minimum delay between the requests to a host,
+	// the robots.txt Crawl-delay is used when it is longer.
+	Delay time.Duration
+
+	// Parallel is the number of files downloaded concurrently, defaults to 4.
+	Parallel uint
+
+	// Prepare is called with each request download before it starts, e.g. to set the request headers.
+	Prepare func(d *Download)
+
+	// Done is called when a file download is done, and with the errors of the followed links.
+	Done func(d *Download, err error)
+}
+
+// pageExts are the path extensions of the links requested as possible HTML pages.
+var pageExts = map[string]bool{
+	"":       true,
+	".htm":   true,
+	".html":  true,
+	".xhtml": true,
+	".shtml": true,
+	".php":   true,
+	".asp":   true,
+	".aspx":  true,
+	".jsp":   true,
+	".cgi":   true,
+}
+
+// maxPageSize is the maximum size of the parsed HTML pages.
+const maxPageSize = 16 << 20
+
+// maxQueryName is the maximum length of a query kept in a saved file name.
+const maxQueryName = 100
+
+// spiderLinks are the link attributes of the followed HTML tags.
+var spiderLinks = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"img":    {"src"},
+	"script": {"src"},
+	"iframe": {"src"},
+	"frame":  {"src"},
+	"embed":  {"src"},
+	"source": {"src"},
+	"video":  {"src"},
+	"audio":  {"src"},
+	"track":  {"src"},
+}
+
+type spider struct {
+	Got
+
+	c *SpiderConfig
+
+	domains []string
+
+	robots map[string]*robotsRules
+
+	delays hostDelays
+}
+
+// Spider downloads the start page and follows its links within the depth and domain limits,
+// the accepted pages and files are saved into dir/host/path, with the URL query appended as path@query.
+func (g Got) Spider(URL, dir string, c *SpiderConfig) error {
+
+	if c == nil {
+		c = &SpiderConfig{}
+	}
+
+	type link struct {
+		URL   *url.URL
+		depth int
+	}
+
+	start, err := url.Parse(URL)
+	if err != nil {
+		return err
+	}
+
+	start.Fragment = ""
+
+	s := &spider{
+		Got:     g,
+		c:       c,
+		domains: c.Domains,
+		robots:  make(map[string]*robotsRules),
+		delays:  hostDelays{next: make(map[string]time.Time)},
+	}
+
+	if len(s.domains) == 0 {
+		s.domains = []string{start.Hostname()}
+	}
+
+	var (
+		files   []remoteFile
+		queue   = []link{{start, 0}}
+		visited = map[string]bool{start.String(): true}
+		saved   = make(map[string]bool)
+	)
+
+	add := func(u *url.URL) {
+
+		rel := spiderPath(u)
+
+		if validRelPath(rel) && !saved[rel] {
+			saved[rel] = true
+			files = append(files, remoteFile{URL: u.String(), Path: rel})
+		}
+	}
+
+	for len(queue) > 0 {
+
+		l := queue[0]
+		queue = queue[1:]
+
+		if !s.allowed(l.URL) {
+			continue
+		}
+
+		// The links to files are not requested to check their type.
+		if ext := strings.ToLower(path.Ext(l.URL.Path)); !pageExts[ext] {
+
+			if typ, _, _ := mime.ParseMediaType(mime.TypeByExtension(ext)); s.accepts(l.URL, typ) {
+				add(l.URL)
+			}
+
+			continue
+		}
+
+		d := s.newRequestDownload(l.URL.String())
+
+		if err = s.delays.wait(d.ctx, l.URL.Host, s.delay(l.URL)); err != nil {
+			return err
+		}
+
+		res, err := d.get(l.URL.String())
+		if err != nil {
+
+			// The start page must be downloaded, the broken links are reported to Done.
+			if l.depth == 0 {
+				return err
+			}
+
+			if c.Done != nil {
+				c.Done(d, err)
+			}
+
+			continue
+		}
+
+		typ, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+
+		if !isHTML(typ) || (c.Depth > 0 && l.depth >= c.Depth) {
+
+			res.Body.Close()
+
+			if s.accepts(l.URL, typ) {
+				add(l.URL)
+			}
+
+			continue
+		}
+
+		page, err := io.ReadAll(io.LimitReader(res.Body, maxPageSize))
+		res.Body.Close()
+
+		if err != nil {
+			return err
+		}
+
+		if s.accepts(l.URL, typ) {
+			add(l.URL)
+		}
+
+		base := res.Request.URL
+
+		for _, ref := range htmlLinks(page, spiderLinks) {
+
+			u, err := base.Parse(ref)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !s.follows(u.Hostname()) {
+				continue
+			}
+
+			u.Fragment = ""
+
+			if !visited[u.String()] {
+				visited[u.String()] = true
+				queue = append(queue, link{u, l.depth + 1})
+			}
+		}
+	}
+
+	return s.downloadFiles(files, dir, &RecursiveConfig{
+		Parallel: c.Parallel,
+		Prepare: func(d *Download) {
+
+			if u, err := url.Parse(d.URL); err == nil {
+				s.delays.wait(s.context(), u.Host, s.delay(u))
+			}
+
+			if c.Prepare != nil {
+				c.Prepare(d)
+			}
+		},
+		Done: c.Done,
+	})
+}
+
+// newRequestDownload returns a download used to send page requests.
+func (s *spider) newRequestDownload(URL string) *Download {
+	return s.Got.newRequestDownload(URL, &RecursiveConfig{Prepare: s.c.Prepare})
+}
+
+func (s *spider) context() context.Context {
+
+	if s.ctx == nil {
+		return context.Background()
+	}
+
+	return s.ctx
+}
+
+// follows reports whether the host is one of the domains or their subdomains.
+func (s *spider) follows(host string) bool {
+
+	host = strings.ToLower(host)
+
+	for _, domain := range s.domains {
+
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// accepts reports whether the file with the MIME type passes the Accept and Reject filters.
+func (s *spider) accepts(u *url.URL, typ string) bool {
+
+	name := path.Base(u.Path)
+
+	if len(s.c.Accept) > 0 && !matchFile(s.c.Accept, name, typ) {
+		return false
+	}
+
+	return !matchFile(s.c.Reject, name, typ)
+}
+
+// allowed reports whether the robots.txt rules of the host allow the URL.
+func (s *spider) allowed(u *url.URL) bool {
+
+	if s.c.IgnoreRobots {
+		return true
+	}
+
+	return s.robotsRules(u).allowed(u.EscapedPath(), u.RawQuery)
+}
+
+// delay returns the delay between the requests to the URL host.
+func (s *spider) delay(u *url.URL) time.Duration {
+
+	if s.c.IgnoreRobots {
+		return s.c.Delay
+	}
+
+	if d := s.robotsRules(u).delay; d > s.c.Delay {
+		return d
+	}
+
+	return s.c.Delay
+}
+
+// robotsRules returns the cached robots.txt rules of the URL host,
+// the rules allow everything when robots.txt is missing or can't be downloaded.
+func (s *spider) robotsRules(u *url.URL) *robotsRules {
+
+	key := u.Scheme + "://" + u.Host
+
+	if rules, ok := s.robots[key]; ok {
+		return rules
+	}
+
+	rules := &robotsRules{}
+	s.robots[key] = rules
+
+	d := s.newRequestDownload(key + "/robots.txt")
+
+	if s.delays.wait(d.ctx, u.Host, s.c.Delay) != nil {
+		return rules
+	}
+
+	req, err := d.newRequest(http.MethodGet, key+"/robots.txt")
+	if err != nil || d.hook(req, RequestKind{Type: ProbeRequest}) != nil {
+		return rules
+	}
+
+	res, err := d.do(req)
+	if err != nil {
+		return rules
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusOK {
+
+		if data, err := io.ReadAll(io.LimitReader(res.Body, maxPageSize)); err == nil {
+			*rules = *parseRobots(data, req.Header.Get("User-Agent"))
+		}
+	}
+
+	return rules
+}
+
+// spiderPath returns the slash separated file path of a spider URL,
+// the query is appended to the file name after an "@" so pages that differ by query are all saved.
+func spiderPath(u *url.URL) string {
+
+	p := u.Path
+
+	if p == "" || strings.HasSuffix(p, "/") {
+		p += "index.html"
+	}
+
+	if u.RawQuery != "" {
+		p += "@" + spiderQuery(u.RawQuery)
+	}
+
+	return u.Hostname() + "/" + strings.TrimPrefix(p, "/")
+}
+
+// spiderQuery returns the query with the characters invalid in file names escaped,
+// a long query is replaced by its hash to keep the file name length valid.
+func spiderQuery(query string) string {
+
+	if len(query) > maxQueryName {
+		sum := sha256.Sum256([]byte(query))
+		return hex.EncodeToString(sum[:8])
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(query); i++ {
+
+		if c := query[i]; c < 0x20 || strings.IndexByte(`/\:*?"<>|`, c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+
+		b.WriteByte(query[i])
+	}
+
+	return b.String()
+}
+
+// matchFile reports whether the file name or MIME type matches any pattern.
+func matchFile(patterns []string, name, typ string) bool {
+
+	for _, p := range patterns {
+
+		switch {
+		case strings.Contains(p, "/"):
+			if ok, _ := path.Match(strings.ToLower(p), typ); ok && typ != "" {
+				return true
+			}
+		case strings.ContainsAny(p, "*?["):
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+		default:
+			if strings.EqualFold(path.Ext(name), "."+strings.TrimPrefix(p, ".")) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isHTML(typ string) bool {
+	return typ == "text/html" || typ == "application/xhtml+xml"
+}
+
+// hostDelays spaces the requests to the same host.
+type hostDelays struct {
+	mu sync.Mutex
+
+	next map[string]time.Time
+}
+
+// wait waits until a request to the host is allowed, and delays the next one.
+func (h *hostDelays) wait(ctx context.Context, host string, delay time.Duration) error {
+
+	h.mu.Lock()
+
+	now := time.Now()
+	at := h.next[host]
+
+	if at.Before(now) {
+		at = now
+	}
+
+	h.next[host] = at.Add(delay)
+	h.mu.Unlock()
+
+	if wait := time.Until(at); wait > 0 {
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// robotsRules holds the robots.txt rules of a user agent.
+type robotsRules struct {
+	rules []robotsRule
+
+	delay time.Duration
+}
+
+type robotsRule struct {
+	allow bool
+
+	pattern *regexp.Regexp
+
+	length int
+}
+
+// parseRobots returns the robots.txt rules of the user agent groups matching the agent
+// product token, or the rules of the * groups.
+func parseRobots(data []byte, agent string) *robotsRules {
+
+	agent, _, _ = strings.Cut(strings.ToLower(agent), "/")
+
+	var (
+		matched, fallback robotsRules
+		group             []string
+		inRules           bool
+		hasMatch          bool
+	)
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+
+	for sc.Scan() {
+
+		line, _, _ := strings.Cut(sc.Text(), "#")
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+
+			if inRules {
+				group, inRules = nil, false
+			}
+
+			group = append(group, strings.ToLower(value))
+			continue
+		}
+
+		inRules = true
+
+		for _, token := range group {
+
+			var target *robotsRules
+
+			switch {
+			case token == "*":
+				target = &fallback
+			case token != "" && strings.Contains(agent, token):
+				target, hasMatch = &matched, true
+			default:
+				continue
+			}
+
+			switch key {
+			case "allow", "disallow":
+				if value != "" {
+					target.rules = append(target.rules, robotsRule{
+						allow:   key == "allow",
+						pattern: robotsPattern(value),
+						length:  len(value),
+					})
+				}
+			case "crawl-delay":
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					target.delay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	if hasMatch {
+		return &matched
+	}
+
+	return &fallback
+}
+
+// robotsPattern compiles a robots.txt path pattern with the * and $ wildcards.
+func robotsPattern(p string) *regexp.Regexp {
+
+	end := strings.HasSuffix(p, "$")
+	p = regexp.QuoteMeta(strings.TrimSuffix(p, "$"))
+	p = "^" + strings.ReplaceAll(p, `\*`, ".*")
+
+	if end {
+		p += "$"
+	}
+
+	return regexp.MustCompile(p)
+}
+
+// allowed reports whether the longest matching rule allows the path, allow rules win the ties.
+func (r *robotsRules) allowed(escapedPath, query string) bool {
+
+	if escapedPath == "" {
+		escapedPath = "/"
+	}
+
+	if query != "" {
+		escapedPath += "?" + query
+	}
+
+	allow, length := true, -1
+
+	for _, rule := range r.rules {
+
+		if !rule.pattern.MatchString(escapedPath) {
+			continue
+		}
+
+		if rule.length > length || (rule.length == length && rule.allow) {
+			allow, length = rule.allow, rule.length
+		}
+	}
+
+	return allow
+}
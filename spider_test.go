@@ -0,0 +1,177 @@
+package got_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/melbahja/got"
+)
+
+func TestSpider(t *testing.T) {
+
+	pages := map[string]string{
+		"/robots.txt": "User-agent: *\nDisallow: /\n\nUser-agent: Got\nDisallow: /private/\nAllow: /private/open.pdf\nCrawl-delay: 0.01\n",
+		"/": `<html><a href="docs/">Docs</a> <A HREF='/a.pdf'>A</A> <img src="logo.png">
+			<a href="/private/secret.pdf">secret</a> <a href="/private/open.pdf">open</a>
+			<a href="http://other.example/x.pdf">other</a> <a href="mailto:a@example.com">mail</a>
+			<a href="/download">download</a> <a href="/missing.html">missing</a></html>`,
+		"/docs/":              `<a href="b.pdf#page=2">B</a> <a href="deep.html">deep</a> <a href="../">up</a>`,
+		"/docs/deep.html":     `<a href="c.pdf">C</a>`,
+		"/a.pdf":              "a",
+		"/logo.png":           "png",
+		"/private/secret.pdf": "secret",
+		"/private/open.pdf":   "open",
+		"/docs/b.pdf":         "b",
+		"/docs/c.pdf":         "c",
+		"/download":           "download",
+		"/files/":             `<a href="get?id=1">1</a> <a href="get?id=2">2</a> <a href="get?q=a/b">q</a> <a href="get?long=` + strings.Repeat("x", 100) + `">long</a>`,
+		"/files/get":          "get ",
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		content, ok := pages[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/download":
+			w.Header().Set("Content-Type", "application/pdf")
+		case r.URL.Path == "/files/get":
+			w.Header().Set("Content-Type", "application/pdf")
+			content += r.URL.RawQuery
+		case r.URL.Path == "/robots.txt":
+			w.Header().Set("Content-Type", "text/plain")
+		case strings.HasSuffix(r.URL.Path, "/") || strings.HasSuffix(r.URL.Path, ".html"):
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	host := "127.0.0.1/"
+
+	t.Run("acceptTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		var broken []string
+
+		err := got.New().Spider(srv.URL, dir, &got.SpiderConfig{
+			Accept:   []string{"pdf", "application/pdf"},
+			Parallel: 2,
+			Done: func(d *got.Download, err error) {
+				if err != nil {
+					broken = append(broken, d.URL)
+				}
+			},
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectTree(t, dir, []string{"127.0.0.1/", host + "a.pdf", host + "docs/", host + "docs/b.pdf", host + "docs/c.pdf", host + "download", host + "private/", host + "private/open.pdf"})
+
+		if len(broken) != 1 || !strings.HasSuffix(broken[0], "/missing.html") {
+			t.Errorf("Expecting the missing.html broken link, but got %v", broken)
+		}
+
+		if data, _ := os.ReadFile(filepath.Join(dir, "127.0.0.1", "docs", "c.pdf")); string(data) != "c" {
+			t.Errorf("Expecting c.pdf content c, but got %s", data)
+		}
+	})
+
+	t.Run("depthTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		err := got.New().Spider(srv.URL+"/", dir, &got.SpiderConfig{
+			Depth:  2,
+			Reject: []string{"*.png", "text/html"},
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectTree(t, dir, []string{"127.0.0.1/", host + "a.pdf", host + "docs/", host + "docs/b.pdf", host + "download", host + "private/", host + "private/open.pdf"})
+	})
+
+	t.Run("ignoreRobotsTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		err := got.New().Spider(srv.URL, dir, &got.SpiderConfig{
+			Depth:        1,
+			Accept:       []string{"secret.*"},
+			IgnoreRobots: true,
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectTree(t, dir, []string{"127.0.0.1/", host + "private/", host + "private/secret.pdf"})
+	})
+
+	t.Run("delayTest", func(t *testing.T) {
+
+		start := time.Now()
+
+		// The robots.txt, start page, a.pdf and download requests.
+		err := got.New().Spider(srv.URL, t.TempDir(), &got.SpiderConfig{
+			Depth:  1,
+			Accept: []string{"a.pdf", "application/pdf"},
+			Delay:  50 * time.Millisecond,
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+			t.Errorf("Expecting at least 150ms with the host delay, but got %s", elapsed)
+		}
+	})
+
+	t.Run("queryTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		err := got.New().Spider(srv.URL+"/files/", dir, &got.SpiderConfig{
+			Accept: []string{"application/pdf"},
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The long query is replaced by its hash.
+		sum := sha256.Sum256([]byte("long=" + strings.Repeat("x", 100)))
+		long := host + "files/get@" + hex.EncodeToString(sum[:8])
+
+		expectTree(t, dir, []string{"127.0.0.1/", host + "files/", long, host + "files/get@id=1", host + "files/get@id=2", host + "files/get@q=a%2Fb"})
+
+		if data, _ := os.ReadFile(filepath.Join(dir, "127.0.0.1", "files", "get@id=2")); string(data) != "get id=2" {
+			t.Errorf("Expecting get@id=2 content get id=2, but got %s", data)
+		}
+	})
+
+	t.Run("notFoundTest", func(t *testing.T) {
+
+		if err := got.New().Spider(srv.URL+"/404.html", t.TempDir(), nil); err == nil {
+			t.Error("Expecting not found error")
+		}
+	})
+}
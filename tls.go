@@ -0,0 +1,232 @@
+package got
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrPinnedPubKeyMismatch is returned when the server public key doesn't match any of the pinned keys.
+var ErrPinnedPubKeyMismatch = errors.New("Server public key does not match pinned public key")
+
+// TLSConfig holds got TLS options.
+type TLSConfig struct {
+
+	// CACert is the path of a PEM CA bundle used instead of the system roots.
+	CACert string
+
+	// ClientCert and ClientKey are the PEM client certificate and key paths for mutual TLS,
+	// ClientKey defaults to ClientCert when both are in the same file.
+	ClientCert, ClientKey string
+
+	// MinVersion is the minimum TLS version, e.g. tls.VersionTLS12.
+	MinVersion uint16
+
+	// PinnedPubKeys are "sha256//<base64>" hashes of the server public key,
+	// or paths of PEM or DER public keys or certificates.
+	PinnedPubKeys []string
+
+	// Insecure skips the server certificate verification.
+	Insecure bool
+
+	mu sync.Mutex
+
+	built *tls.Config
+
+	// transports caches the transports built from base transports.
+	transports map[http.RoundTripper]*http.Transport
+}
+
+// Transport returns a clone of base transport with the TLS options applied,
+// transports are cached so connections are reused across downloads.
+func (c *TLSConfig) Transport(base http.RoundTripper) (*http.Transport, error) {
+
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if t, ok := c.transports[base]; ok {
+		return t, nil
+	}
+
+	b, ok := base.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("TLS options require an *http.Transport, got %T", base)
+	}
+
+	config, err := c.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	t := b.Clone()
+	t.TLSClientConfig = config
+
+	// Keep the base transport settings that are not set by TLSConfig.
+	if b.TLSClientConfig != nil {
+		t.TLSClientConfig.ServerName = b.TLSClientConfig.ServerName
+		t.TLSClientConfig.NextProtos = b.TLSClientConfig.NextProtos
+	}
+
+	if c.transports == nil {
+		c.transports = make(map[http.RoundTripper]*http.Transport)
+	}
+
+	c.transports[base] = t
+
+	return t, nil
+}
+
+// Config returns a new *tls.Config with the TLS options applied.
+func (c *TLSConfig) Config() (*tls.Config, error) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.clientConfig()
+}
+
+// clientConfig returns a clone of the built config, it must be called with the lock held.
+func (c *TLSConfig) clientConfig() (*tls.Config, error) {
+
+	if c.built == nil {
+
+		config, err := c.build()
+		if err != nil {
+			return nil, err
+		}
+
+		c.built = config
+	}
+
+	return c.built.Clone(), nil
+}
+
+func (c *TLSConfig) build() (*tls.Config, error) {
+
+	config := &tls.Config{
+		MinVersion:         c.MinVersion,
+		InsecureSkipVerify: c.Insecure,
+	}
+
+	if c.CACert != "" {
+
+		data, err := os.ReadFile(c.CACert)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("No valid certificates found in CA file: %s", c.CACert)
+		}
+	}
+
+	if c.ClientCert != "" {
+
+		key := c.ClientKey
+		if key == "" {
+			key = c.ClientCert
+		}
+
+		cert, err := tls.LoadX509KeyPair(c.ClientCert, key)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(c.PinnedPubKeys) > 0 {
+
+		pins, err := loadPins(c.PinnedPubKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		// VerifyConnection is called even when Insecure is set.
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+
+			if len(cs.PeerCertificates) == 0 {
+				return ErrPinnedPubKeyMismatch
+			}
+
+			sum := sha256.Sum256(cs.PeerCertificates[0].RawSubjectPublicKeyInfo)
+
+			if !pins[base64.StdEncoding.EncodeToString(sum[:])] {
+				return ErrPinnedPubKeyMismatch
+			}
+
+			return nil
+		}
+	}
+
+	return config, nil
+}
+
+// loadPins returns the set of base64 sha256 public key hashes.
+func loadPins(keys []string) (map[string]bool, error) {
+
+	pins := make(map[string]bool)
+
+	for _, key := range keys {
+
+		// Many hashes can be separated with ";" like curl.
+		for _, k := range strings.Split(key, ";") {
+
+			if k = strings.TrimSpace(k); k == "" {
+				continue
+			}
+
+			if strings.HasPrefix(k, "sha256//") {
+				pins[strings.TrimPrefix(k, "sha256//")] = true
+				continue
+			}
+
+			spki, err := loadPublicKey(k)
+			if err != nil {
+				return nil, err
+			}
+
+			sum := sha256.Sum256(spki)
+			pins[base64.StdEncoding.EncodeToString(sum[:])] = true
+		}
+	}
+
+	return pins, nil
+}
+
+// loadPublicKey returns the DER subject public key info from a PEM or DER public key or certificate file.
+func loadPublicKey(path string) ([]byte, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+
+	if cert, err := x509.ParseCertificate(data); err == nil {
+		return cert.RawSubjectPublicKeyInfo, nil
+	}
+
+	if _, err := x509.ParsePKIXPublicKey(data); err != nil {
+		return nil, fmt.Errorf("Invalid public key file: %s", path)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,153 @@
+package got_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/melbahja/got"
+)
+
+func TestTLS(t *testing.T) {
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "go.mod")
+	}))
+	defer srv.Close()
+
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", srv.Certificate().Raw)
+
+	sum := sha256.Sum256(srv.Certificate().RawSubjectPublicKeyInfo)
+	pin := "sha256//" + base64.StdEncoding.EncodeToString(sum[:])
+
+	t.Run("unknownCATest", func(t *testing.T) {
+		downloadWithTLS(t, srv.URL, nil, false)
+	})
+
+	t.Run("caCertTest", func(t *testing.T) {
+		downloadWithTLS(t, srv.URL, &got.TLSConfig{CACert: caFile}, true)
+	})
+
+	t.Run("insecureTest", func(t *testing.T) {
+		downloadWithTLS(t, srv.URL, &got.TLSConfig{Insecure: true}, true)
+	})
+
+	t.Run("pinnedPubKeyTest", func(t *testing.T) {
+		downloadWithTLS(t, srv.URL, &got.TLSConfig{CACert: caFile, PinnedPubKeys: []string{"sha256//invalid;" + pin}}, true)
+		downloadWithTLS(t, srv.URL, &got.TLSConfig{Insecure: true, PinnedPubKeys: []string{caFile}}, true)
+		downloadWithTLS(t, srv.URL, &got.TLSConfig{Insecure: true, PinnedPubKeys: []string{"sha256//invalid"}}, false)
+	})
+
+	t.Run("minVersionTest", func(t *testing.T) {
+
+		srv := httptest.NewUnstartedServer(srv.Config.Handler)
+		srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+		srv.StartTLS()
+		defer srv.Close()
+
+		downloadWithTLS(t, srv.URL, &got.TLSConfig{Insecure: true, MinVersion: tls.VersionTLS12}, true)
+		downloadWithTLS(t, srv.URL, &got.TLSConfig{Insecure: true, MinVersion: tls.VersionTLS13}, false)
+	})
+
+	t.Run("clientCertTest", func(t *testing.T) {
+
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		cert := createClientCert(t, certFile, keyFile)
+
+		pool := x509.NewCertPool()
+		pool.AddCert(cert)
+
+		srv := httptest.NewUnstartedServer(srv.Config.Handler)
+		srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+		srv.StartTLS()
+		defer srv.Close()
+
+		downloadWithTLS(t, srv.URL, &got.TLSConfig{Insecure: true}, false)
+		downloadWithTLS(t, srv.URL, &got.TLSConfig{Insecure: true, ClientCert: certFile, ClientKey: keyFile}, true)
+	})
+}
+
+func downloadWithTLS(t *testing.T, URL string, config *got.TLSConfig, ok bool) {
+
+	tmpFile := createTemp()
+	defer clean(tmpFile)
+
+	g := got.New()
+	g.TLS = config
+
+	err := g.Do(&got.Download{URL: URL, Dest: tmpFile, ChunkSize: 50})
+
+	if ok && err != nil {
+		t.Error(err)
+	}
+
+	if !ok && err == nil {
+		t.Errorf("Expecting TLS error with %+v", config)
+	}
+}
+
+func createClientCert(t *testing.T, certFile, keyFile string) *x509.Certificate {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "got"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDer)
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func writePEM(t *testing.T, name, typ string, der []byte) {
+
+	if err := os.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,289 @@
+package got
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrUnchanged is passed to RecursiveConfig.Done for the files skipped by SkipUnchanged.
+var ErrUnchanged = errors.New("File is unchanged")
+
+// etagsFile is the file name of the saved ETags in the download directory.
+const etagsFile = ".got.etags"
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop>
+    <d:resourcetype/>
+    <d:getcontentlength/>
+    <d:getetag/>
+  </d:prop>
+</d:propfind>`
+
+type (
+	davMultistatus struct {
+		Responses []davResponse `xml:"DAV: response"`
+	}
+
+	davResponse struct {
+		Href      string        `xml:"DAV: href"`
+		Propstats []davPropstat `xml:"DAV: propstat"`
+	}
+
+	davPropstat struct {
+		Status string `xml:"DAV: status"`
+		Prop   struct {
+			Collection    *struct{} `xml:"DAV: resourcetype>collection"`
+			ContentLength string    `xml:"DAV: getcontentlength"`
+			ETag          string    `xml:"DAV: getetag"`
+		} `xml:"DAV: prop"`
+	}
+)
+
+// DownloadWebDAV downloads the files of a WebDAV collection and its sub collections into dir,
+// the collections are listed with PROPFIND requests.
+func (g Got) DownloadWebDAV(URL, dir string, c *RecursiveConfig) error {
+
+	if c == nil {
+		c = &RecursiveConfig{}
+	}
+
+	files, err := g.listWebDAV(URL, dir, c)
+	if err != nil {
+		return err
+	}
+
+	return g.downloadFiles(files, dir, c)
+}
+
+// listWebDAV lists the files below the collection URL, and creates the directories.
+func (g Got) listWebDAV(URL, dir string, c *RecursiveConfig) ([]remoteFile, error) {
+
+	type collection struct {
+		URL   *url.URL
+		depth int
+	}
+
+	root, err := url.Parse(URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(root.Path, "/") {
+		root.Path, root.RawPath = root.Path+"/", ""
+	}
+
+	var (
+		files   []remoteFile
+		queue   = []collection{{root, 1}}
+		visited = map[string]bool{root.Path: true}
+	)
+
+	for len(queue) > 0 {
+
+		col := queue[0]
+		queue = queue[1:]
+
+		responses, err := g.propfind(col.URL.String(), c)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", col.URL, err)
+		}
+
+		for _, res := range responses {
+
+			// Hrefs on another host are skipped, the credentials are only sent to the root host.
+			u, err := col.URL.Parse(strings.TrimSpace(res.Href))
+			if err != nil || u.Scheme != root.Scheme || u.Host != root.Host || !strings.HasPrefix(u.Path, root.Path) || visited[u.Path] {
+				continue
+			}
+
+			visited[u.Path] = true
+
+			var (
+				props   = res.props()
+				isDir   = props.Collection != nil
+				rel     = strings.TrimSuffix(strings.TrimPrefix(u.Path, root.Path), "/")
+				size, _ = strconv.ParseUint(props.ContentLength, 10, 64)
+			)
+
+			if rel == "" || !validRelPath(rel) || matchAny(c.Exclude, rel) {
+				continue
+			}
+
+			if isDir {
+
+				if c.Depth > 0 && col.depth >= c.Depth {
+					continue
+				}
+
+				if err = os.MkdirAll(filepath.Join(dir, filepath.FromSlash(rel)), os.ModePerm); err != nil {
+					return nil, err
+				}
+
+				if !strings.HasSuffix(u.Path, "/") {
+					u.Path, u.RawPath = u.Path+"/", ""
+				}
+
+				queue = append(queue, collection{u, col.depth + 1})
+				continue
+			}
+
+			if len(c.Include) > 0 && !matchAny(c.Include, rel) {
+				continue
+			}
+
+			files = append(files, remoteFile{URL: u.String(), Path: rel, Size: size, ETag: props.ETag})
+		}
+	}
+
+	return files, nil
+}
+
+// propfind returns the responses of a Depth 1 PROPFIND request.
+func (g Got) propfind(URL string, c *RecursiveConfig) ([]davResponse, error) {
+
+	d := g.newRequestDownload(URL, c)
+
+	req, err := d.newRequest("PROPFIND", URL)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(propfindBody)), nil
+	}
+	req.Body, _ = req.GetBody()
+	req.ContentLength = int64(len(propfindBody))
+
+	if err = d.hook(req, RequestKind{Type: ProbeRequest}); err != nil {
+		return nil, err
+	}
+
+	res, err := d.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusMultiStatus {
+		return nil, statusError(res.StatusCode)
+	}
+
+	var ms davMultistatus
+
+	if err = xml.NewDecoder(res.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("Invalid PROPFIND response: %w", err)
+	}
+
+	return ms.Responses, nil
+}
+
+// props returns the found properties of the response.
+func (r *davResponse) props() (props struct {
+	Collection    *struct{}
+	ContentLength string
+	ETag          string
+}) {
+
+	for _, ps := range r.Propstats {
+
+		if fields := strings.Fields(ps.Status); len(fields) > 1 && fields[1] != "200" {
+			continue
+		}
+
+		if ps.Prop.Collection != nil {
+			props.Collection = ps.Prop.Collection
+		}
+
+		props.ContentLength = firstOf(props.ContentLength, ps.Prop.ContentLength)
+		props.ETag = firstOf(props.ETag, ps.Prop.ETag)
+	}
+
+	return props
+}
+
+// etags holds the ETags of the downloaded files of a directory.
+type etags struct {
+	path string
+
+	mu sync.Mutex
+
+	tags map[string]string
+}
+
+func loadETags(dir string) (*etags, error) {
+
+	e := &etags{path: filepath.Join(dir, etagsFile), tags: make(map[string]string)}
+
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return e, nil
+		}
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, &e.tags); err != nil {
+		return nil, fmt.Errorf("Invalid ETags file %s: %w", e.path, err)
+	}
+
+	return e, nil
+}
+
+// unchanged reports whether the file has the same ETag as its last download and the local file has the same size.
+func (e *etags) unchanged(f remoteFile, dest string) bool {
+
+	e.mu.Lock()
+	tag := e.tags[f.Path]
+	e.mu.Unlock()
+
+	if f.ETag == "" || tag != f.ETag {
+		return false
+	}
+
+	stat, err := os.Stat(dest)
+
+	return err == nil && !stat.IsDir() && uint64(stat.Size()) == f.Size
+}
+
+func (e *etags) set(f remoteFile) {
+
+	if f.ETag == "" {
+		return
+	}
+
+	e.mu.Lock()
+	e.tags[f.Path] = f.ETag
+	e.mu.Unlock()
+}
+
+func (e *etags) save() error {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(e.tags); err != nil {
+		return err
+	}
+
+	return os.WriteFile(e.path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,251 @@
+package got_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/melbahja/got"
+)
+
+func TestDownloadWebDAV(t *testing.T) {
+
+	tree := map[string]string{
+		"/dav/a.txt":          "a",
+		"/dav/sub/b.txt":      "bb",
+		"/dav/sub/deep/c.log": "ccc",
+		"/dav/sub dir/d.txt":  "dddd",
+		"/other.txt":          "other",
+	}
+
+	var gets, foreignGets int32
+
+	// foreign is another host listed in the root collection, it must not be requested.
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&foreignGets, 1)
+		w.Write([]byte("foreign"))
+	}))
+	defer foreign.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="dav"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+
+			content, ok := tree[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			atomic.AddInt32(&gets, 1)
+			w.Write([]byte(content))
+			return
+		}
+
+		if r.Method != "PROPFIND" || r.Header.Get("Depth") != "1" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		dir := r.URL.Path
+		if !strings.HasSuffix(dir, "/") || !strings.HasPrefix(dir, "/dav/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		children := make(map[string]bool)
+
+		for name := range tree {
+			if rest := strings.TrimPrefix(name, dir); rest != name {
+				if i := strings.Index(rest, "/"); i >= 0 {
+					children[dir+rest[:i+1]] = true
+				} else {
+					children[name] = true
+				}
+			}
+		}
+
+		names := []string{dir}
+		for name := range children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+
+		fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><D:multistatus xmlns:D="DAV:">`)
+
+		for _, name := range names {
+
+			href := (&url.URL{Path: name}).EscapedPath()
+
+			if strings.HasSuffix(name, "/") {
+				fmt.Fprintf(w, `<D:response><D:href>%s</D:href><D:propstat><D:prop><D:resourcetype><D:collection/></D:resourcetype></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`, href)
+				continue
+			}
+
+			fmt.Fprintf(w, `<D:response><D:href>%s</D:href><D:propstat><D:prop><D:resourcetype/><D:getcontentlength>%d</D:getcontentlength><D:getetag>"%x"</D:getetag></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`, href, len(tree[name]), tree[name])
+		}
+
+		if dir == "/dav/" {
+			fmt.Fprintf(w, `<D:response><D:href>%s/dav/foreign.txt</D:href><D:propstat><D:prop><D:resourcetype/></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`, foreign.URL)
+		}
+
+		fmt.Fprint(w, `</D:multistatus>`)
+	}))
+	defer srv.Close()
+
+	auth := &got.Auth{Username: "user", Password: "pass"}
+
+	t.Run("mirrorTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		g := got.New()
+		g.Auth = auth
+
+		if err := g.DownloadWebDAV(srv.URL+"/dav", dir, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		expectTree(t, dir, []string{"a.txt", "sub dir/", "sub dir/d.txt", "sub/", "sub/b.txt", "sub/deep/", "sub/deep/c.log"})
+
+		if n := atomic.LoadInt32(&foreignGets); n != 0 {
+			t.Errorf("Expecting no foreign host requests, but got %d", n)
+		}
+
+		for name, content := range tree {
+
+			if !strings.HasPrefix(name, "/dav/") {
+				continue
+			}
+
+			if data, _ := os.ReadFile(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(name, "/dav/")))); string(data) != content {
+				t.Errorf("Expecting %s content %s, but got %s", name, content, data)
+			}
+		}
+	})
+
+	t.Run("filtersTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		g := got.New()
+		g.Auth = auth
+
+		err := g.DownloadWebDAV(srv.URL+"/dav/", dir, &got.RecursiveConfig{
+			Include: []string{"*.txt"},
+			Depth:   2,
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectTree(t, dir, []string{"a.txt", "sub dir/", "sub dir/d.txt", "sub/", "sub/b.txt"})
+	})
+
+	t.Run("skipUnchangedTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		g := got.New()
+		g.Auth = auth
+
+		var (
+			mu      sync.Mutex
+			skipped []string
+		)
+
+		c := &got.RecursiveConfig{
+			SkipUnchanged: true,
+			Done: func(d *got.Download, err error) {
+				if errors.Is(err, got.ErrUnchanged) {
+					mu.Lock()
+					skipped = append(skipped, path.Base(d.URL))
+					mu.Unlock()
+				}
+			},
+		}
+
+		if err := g.DownloadWebDAV(srv.URL+"/dav/", dir, c); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(skipped) != 0 {
+			t.Errorf("Expecting no skipped files, but got %v", skipped)
+		}
+
+		// A changed local file is downloaded again.
+		os.WriteFile(filepath.Join(dir, "a.txt"), []byte("changed"), 0644)
+
+		before := atomic.LoadInt32(&gets)
+
+		if err := g.DownloadWebDAV(srv.URL+"/dav/", dir, c); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := atomic.LoadInt32(&gets) - before; n != 1 {
+			t.Errorf("Expecting 1 GET request, but got %d", n)
+		}
+
+		if len(skipped) != 3 {
+			t.Errorf("Expecting 3 skipped files, but got %v", skipped)
+		}
+
+		if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "a" {
+			t.Errorf("Expecting a.txt content a, but got %s", data)
+		}
+	})
+
+	t.Run("saveErrorTest", func(t *testing.T) {
+
+		dir := t.TempDir()
+
+		g := got.New()
+		g.Auth = auth
+
+		// A directory in place of the ETags file makes saving it fail,
+		// it's created once the file downloads start.
+		err := g.DownloadWebDAV(srv.URL+"/dav/", dir, &got.RecursiveConfig{
+			SkipUnchanged: true,
+			Prepare: func(d *got.Download) {
+				if d.Dest != "" {
+					os.MkdirAll(filepath.Join(dir, ".got.etags"), os.ModePerm)
+				}
+			},
+		})
+
+		if err == nil {
+			t.Error("Expecting ETags save error")
+		}
+
+		if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "a" {
+			t.Errorf("Expecting a.txt content a, but got %s", data)
+		}
+	})
+
+	t.Run("unauthorizedTest", func(t *testing.T) {
+
+		if err := got.New().DownloadWebDAV(srv.URL+"/dav/", t.TempDir(), nil); err == nil {
+			t.Error("Expecting unauthorized error")
+		}
+	})
+}